@@ -18,11 +18,13 @@ func main() {
 
 	switch strings.ToLower(command) {
 	case "help", "-h", "--help":
+		if len(os.Args) > 2 && cli.PrintCommandHelp(os.Args[2]) {
+			return
+		}
 		cli.PrintHelp(Version)
 	case "version", "-v", "--version":
 		fmt.Printf("Cracky v%s\n", Version)
 	default:
-		opts := cli.ParseArgs(os.Args[1:])
-		cli.Run(opts)
+		cli.Execute(os.Args[1:], Version)
 	}
 }
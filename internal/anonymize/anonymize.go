@@ -0,0 +1,313 @@
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password replacement modes
+const (
+	// PasswordsMask replaces each character with a keyed random character of
+	// the same class and UTF-8 length, preserving length, mask and complexity
+	PasswordsMask = "mask"
+	// PasswordsHash replaces passwords with a keyed hash, preserving only equality
+	PasswordsHash = "hash"
+)
+
+// maxAttempts bounds the re-derivations when a token is already taken by
+// another password; only very short passwords can run out of tokens
+const maxAttempts = 64
+
+// Anonymizer derives stable pseudonyms from a per-engagement secret. The
+// same secret always gives the same pseudonyms, so files anonymized
+// separately can still be joined.
+type Anonymizer struct {
+	key []byte
+
+	// tokens maps each password token to the password it was derived from,
+	// so distinct passwords never share a token
+	tokens    map[string]string
+	passwords map[string]string
+}
+
+// New returns an anonymizer keyed with the secret
+func New(secret []byte) *Anonymizer {
+	return &Anonymizer{
+		key:       secret,
+		tokens:    make(map[string]string),
+		passwords: make(map[string]string),
+	}
+}
+
+// sum returns HMAC-SHA256(key, label || 0 || value)
+func (a *Anonymizer) sum(label, value string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(label))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// Name returns a pseudonym for an account name: the prefix followed by 10
+// hex characters. Names are compared case-insensitively, as in Active Directory.
+func (a *Anonymizer) Name(prefix, name string) string {
+	return prefix + hex.EncodeToString(a.sum("name", strings.ToLower(name)))[:10]
+}
+
+// Domain returns a pseudonym for a NetBIOS domain name
+func (a *Anonymizer) Domain(domain string) string {
+	return "DOMAIN-" + strings.ToUpper(hex.EncodeToString(a.sum("domain", strings.ToLower(domain)))[:6])
+}
+
+// NTHash returns a keyed replacement for an NT hash with the same format
+func (a *Anonymizer) NTHash(hash string) string {
+	return hex.EncodeToString(a.sum("nt-hash", strings.ToLower(hash)))[:32]
+}
+
+// Password returns the token replacing a password in the given mode. Empty
+// passwords stay empty, equal passwords get equal tokens and distinct
+// passwords get distinct tokens.
+func (a *Anonymizer) Password(password, mode string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if token, ok := a.passwords[mode+"\x00"+password]; ok {
+		return token, nil
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		var token string
+		switch mode {
+		case PasswordsMask:
+			token = a.maskToken(password, attempt)
+		case PasswordsHash:
+			token = a.hashToken(password, attempt)
+		default:
+			return "", fmt.Errorf("unknown password mode '%s' (expected %s or %s)", mode, PasswordsMask, PasswordsHash)
+		}
+
+		if _, taken := a.tokens[mode+"\x00"+token]; taken {
+			continue
+		}
+		a.tokens[mode+"\x00"+token] = password
+		a.passwords[mode+"\x00"+password] = token
+		return token, nil
+	}
+	return "", fmt.Errorf("no distinct %s token for a %d-byte password", mode, len(password))
+}
+
+// RelatedPassword returns the mask token of a password derived from the token
+// of a related password by replaying the edits between the two passwords, so
+// the tokens keep the edit distance of the passwords (Summer2024 and
+// Summer2024! give tokens one edit apart). If the password already has a
+// token, or the derived token is taken, the regular token is returned.
+func (a *Anonymizer) RelatedPassword(password, related string) (string, error) {
+	if token, ok := a.passwords[PasswordsMask+"\x00"+password]; ok || password == "" {
+		return token, nil
+	}
+	if !utf8.ValidString(password) || !utf8.ValidString(related) {
+		return a.Password(password, PasswordsMask)
+	}
+	relatedToken, err := a.Password(related, PasswordsMask)
+	if err != nil {
+		return "", err
+	}
+
+	stream := &keyStream{a: a, label: "password-edit", value: password}
+	from, to, tokenRunes := []rune(related), []rune(password), []rune(relatedToken)
+	var b strings.Builder
+	for _, op := range editScript(from, to) {
+		if op.from >= 0 && op.to >= 0 && from[op.from] == to[op.to] {
+			b.WriteRune(tokenRunes[op.from])
+			continue
+		}
+		if op.to < 0 {
+			continue
+		}
+
+		// Substituted or inserted character: draw one of the same class and
+		// size, different from the replaced token character
+		r := to[op.to]
+		set := alphabet(classify(r), utf8.RuneLen(r))
+		if len(set) == 0 {
+			b.WriteRune(r)
+			continue
+		}
+		c := set[stream.next()%uint32(len(set))]
+		if op.from >= 0 && c == tokenRunes[op.from] && len(set) > 1 {
+			c = set[(stream.next()%uint32(len(set)-1)+uint32(indexOf(set, c))+1)%uint32(len(set))]
+		}
+		b.WriteRune(c)
+	}
+
+	token := b.String()
+	if _, taken := a.tokens[PasswordsMask+"\x00"+token]; taken {
+		return a.Password(password, PasswordsMask)
+	}
+	a.tokens[PasswordsMask+"\x00"+token] = password
+	a.passwords[PasswordsMask+"\x00"+password] = token
+	return token, nil
+}
+
+// editOp aligns a rune of the source (from) with a rune of the target (to);
+// -1 marks an insertion or deletion
+type editOp struct {
+	from, to int
+}
+
+// editScript returns a minimal Levenshtein alignment of two rune slices
+func editScript(from, to []rune) []editOp {
+	dist := make([][]int, len(from)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(to)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+	for i := 1; i <= len(from); i++ {
+		for j := 1; j <= len(to); j++ {
+			cost := 1
+			if from[i-1] == to[j-1] {
+				cost = 0
+			}
+			dist[i][j] = min(dist[i-1][j]+1, dist[i][j-1]+1, dist[i-1][j-1]+cost)
+		}
+	}
+
+	var ops []editOp
+	i, j := len(from), len(to)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && dist[i][j] == dist[i-1][j-1]+boolToInt(from[i-1] != to[j-1]):
+			ops = append(ops, editOp{i - 1, j - 1})
+			i, j = i-1, j-1
+		case i > 0 && dist[i][j] == dist[i-1][j]+1:
+			ops = append(ops, editOp{i - 1, -1})
+			i--
+		default:
+			ops = append(ops, editOp{-1, j - 1})
+			j--
+		}
+	}
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func indexOf(set []rune, r rune) int {
+	for i, c := range set {
+		if c == r {
+			return i
+		}
+	}
+	return 0
+}
+
+func (a *Anonymizer) hashToken(password string, attempt int) string {
+	return "hmac:" + hex.EncodeToString(a.sum(fmt.Sprintf("password-hash/%d", attempt), password))[:24]
+}
+
+func (a *Anonymizer) maskToken(password string, attempt int) string {
+	stream := &keyStream{a: a, label: fmt.Sprintf("password-mask/%d", attempt), value: password}
+
+	var b strings.Builder
+	for i := 0; i < len(password); {
+		// Invalid bytes decode as U+FFFD, a symbol, but are replaced by a
+		// one-byte symbol to keep the byte length
+		r, size := utf8.DecodeRuneInString(password[i:])
+		i += size
+
+		set := alphabet(classify(r), size)
+		if len(set) == 0 {
+			b.WriteString(string(r))
+			continue
+		}
+		b.WriteRune(set[stream.next()%uint32(len(set))])
+	}
+	return b.String()
+}
+
+// keyStream yields pseudo-random numbers derived from the key and a value
+type keyStream struct {
+	a     *Anonymizer
+	label string
+	value string
+	block []byte
+	n     int
+}
+
+func (s *keyStream) next() uint32 {
+	if len(s.block) < 4 {
+		s.block = s.a.sum(fmt.Sprintf("%s/%d", s.label, s.n), s.value)
+		s.n++
+	}
+	v := binary.BigEndian.Uint32(s.block)
+	s.block = s.block[4:]
+	return v
+}
+
+// Character classes, matching the categories of the complexity check and
+// the hashcat mask
+const (
+	classUpper = iota
+	classLower
+	classDigit
+	classSpecial
+	classOther
+	classCount
+)
+
+func classify(r rune) int {
+	switch {
+	case unicode.IsUpper(r):
+		return classUpper
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsDigit(r):
+		return classDigit
+	case unicode.IsPunct(r) || unicode.IsSymbol(r):
+		return classSpecial
+	default:
+		return classOther
+	}
+}
+
+var (
+	alphabetsOnce sync.Once
+	// alphabets holds the printable replacement characters by class and UTF-8 length
+	alphabets [classCount][utf8.UTFMax + 1][]rune
+)
+
+// alphabet returns the replacement characters for a class and encoded length
+func alphabet(class, size int) []rune {
+	alphabetsOnce.Do(func() {
+		for r := rune(0x20); r <= 0x1FFFF; r++ {
+			if r == 0x7F || (r >= 0xD800 && r <= 0xDFFF) || !unicode.IsGraphic(r) {
+				continue
+			}
+			class := classify(r)
+			size := utf8.RuneLen(r)
+			alphabets[class][size] = append(alphabets[class][size], r)
+		}
+	})
+	if size < 1 || size > utf8.UTFMax {
+		return nil
+	}
+	return alphabets[class][size]
+}
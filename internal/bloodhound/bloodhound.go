@@ -0,0 +1,187 @@
+package bloodhound
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+)
+
+// Exporter converts cracked accounts into BloodHound "owned" markings
+type Exporter struct {
+	// Domains maps NetBIOS domain names to FQDNs (CORP -> corp.local)
+	Domains map[string]string
+	// DomainSIDs maps NetBIOS domain names to domain SIDs, used to build
+	// object identifiers for the JSON ingest file
+	DomainSIDs map[string]string
+	// Redact returns the password form written to the node note
+	Redact func(string) string
+}
+
+// NoteProperty is the node property holding the note on the cracked
+// password. The analyst's own notes property is left untouched.
+const NoteProperty = "hashtocrack_note"
+
+// ParseMapping parses NETBIOS=value pairs into a map keyed by uppercase NetBIOS name
+func ParseMapping(pairs []string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range pairs {
+		idx := strings.Index(pair, "=")
+		if idx <= 0 || idx == len(pair)-1 {
+			return nil, fmt.Errorf("invalid mapping '%s', expected NETBIOS=value", pair)
+		}
+		mapping[strings.ToUpper(strings.TrimSpace(pair[:idx]))] = strings.TrimSpace(pair[idx+1:])
+	}
+	return mapping, nil
+}
+
+// NodeName converts DOMAIN\user to BloodHound's USER@DOMAIN.FQDN
+func (e *Exporter) NodeName(username string) string {
+	domain, name := ntds.SplitUsername(username)
+	if domain == "" {
+		return strings.ToUpper(name)
+	}
+	if fqdn, ok := e.Domains[strings.ToUpper(domain)]; ok {
+		domain = fqdn
+	}
+	return strings.ToUpper(name + "@" + domain)
+}
+
+// objectID builds the account SID from the domain SID and RID, if known
+func (e *Exporter) objectID(entry *ntds.CrackedEntry) string {
+	domain, _ := ntds.SplitUsername(entry.Username)
+	sid, ok := e.DomainSIDs[strings.ToUpper(domain)]
+	if !ok || entry.RID == "" {
+		return ""
+	}
+	return strings.ToUpper(sid) + "-" + entry.RID
+}
+
+// UnmappedDomains returns the NetBIOS names of cracked users with no FQDN mapping
+func (e *Exporter) UnmappedDomains(entries []*ntds.CrackedEntry) []string {
+	seen := make(map[string]bool)
+	var unmapped []string
+	for _, entry := range owned(entries) {
+		domain, _ := ntds.SplitUsername(entry.Username)
+		domain = strings.ToUpper(domain)
+		if _, ok := e.Domains[domain]; !ok && domain != "" && !seen[domain] {
+			seen[domain] = true
+			unmapped = append(unmapped, domain)
+		}
+	}
+	return unmapped
+}
+
+func (e *Exporter) note(entry *ntds.CrackedEntry) string {
+	password := entry.Password
+	if e.Redact != nil {
+		password = e.Redact(password)
+	}
+	return "Password cracked by HashToCrack: " + password
+}
+
+// owned returns the cracked user accounts. Machine accounts are not
+// exported, nor accounts without a domain, whose node name is unknown.
+func owned(entries []*ntds.CrackedEntry) []*ntds.CrackedEntry {
+	var result []*ntds.CrackedEntry
+	for _, entry := range entries {
+		if entry.Cracked && !entry.IsMachine && !domainless(entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// domainless reports whether an account has no DOMAIN\ prefix
+func domainless(entry *ntds.CrackedEntry) bool {
+	domain, _ := ntds.SplitUsername(entry.Username)
+	return domain == ""
+}
+
+// Domainless returns the number of cracked user accounts left out of the
+// exports because they have no domain
+func Domainless(entries []*ntds.CrackedEntry) int {
+	count := 0
+	for _, entry := range entries {
+		if entry.Cracked && !entry.IsMachine && domainless(entry) {
+			count++
+		}
+	}
+	return count
+}
+
+// cypherString quotes a value as a Cypher string literal
+func cypherString(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, "'", "\\'")
+	return "'" + value + "'"
+}
+
+// WriteCypher writes a Cypher script marking the cracked users as owned
+func (e *Exporter) WriteCypher(w io.Writer, entries []*ntds.CrackedEntry) (int, error) {
+	count := 0
+	for _, entry := range owned(entries) {
+		_, err := fmt.Fprintf(w, "MATCH (u:User {name: %s}) SET u.owned = true, u.password_cracked = true, u.%s = %s;\n",
+			cypherString(e.NodeName(entry.Username)), NoteProperty, cypherString(e.note(entry)))
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+type ingestUser struct {
+	ObjectIdentifier string                 `json:"ObjectIdentifier"`
+	Properties       map[string]interface{} `json:"Properties"`
+}
+
+type ingestFile struct {
+	Data []ingestUser `json:"data"`
+	Meta struct {
+		Methods int    `json:"methods"`
+		Type    string `json:"type"`
+		Count   int    `json:"count"`
+		Version int    `json:"version"`
+	} `json:"meta"`
+}
+
+// WriteIngest writes a BloodHound CE compatible users.json setting the same properties.
+// Accounts whose domain SID is unknown cannot be identified and are skipped.
+func (e *Exporter) WriteIngest(w io.Writer, entries []*ntds.CrackedEntry) (int, int, error) {
+	file := ingestFile{Data: []ingestUser{}}
+	skipped := 0
+
+	for _, entry := range owned(entries) {
+		id := e.objectID(entry)
+		if id == "" {
+			skipped++
+			continue
+		}
+		name := e.NodeName(entry.Username)
+		domain := ""
+		if idx := strings.Index(name, "@"); idx != -1 {
+			domain = name[idx+1:]
+		}
+		file.Data = append(file.Data, ingestUser{
+			ObjectIdentifier: id,
+			Properties: map[string]interface{}{
+				"name":             name,
+				"domain":           domain,
+				"owned":            true,
+				"password_cracked": true,
+				NoteProperty:       e.note(entry),
+			},
+		})
+	}
+
+	file.Meta.Type = "users"
+	file.Meta.Count = len(file.Data)
+	file.Meta.Version = 5
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return len(file.Data), skipped, enc.Encode(file)
+}
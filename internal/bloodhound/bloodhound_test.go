@@ -0,0 +1,106 @@
+package bloodhound
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+)
+
+var entries = []*ntds.CrackedEntry{
+	{Entry: ntds.Entry{Username: `CORP\jdoe`, RID: "1101"}, Password: "Summer2024!", Cracked: true},
+	{Entry: ntds.Entry{Username: `LAB\o'brien`, RID: "1102"}, Password: `back\slash`, Cracked: true},
+	{Entry: ntds.Entry{Username: `CORP\asmith`, RID: "1103"}},
+	{Entry: ntds.Entry{Username: `CORP\WS01$`, RID: "1104", IsMachine: true}, Password: "machine", Cracked: true},
+	{Entry: ntds.Entry{Username: "Administrator", RID: "500"}, Password: "local", Cracked: true},
+}
+
+var exporter = &Exporter{
+	Domains:    map[string]string{"CORP": "corp.local"},
+	DomainSIDs: map[string]string{"CORP": "s-1-5-21-1-2-3"},
+	Redact:     func(password string) string { return password[:1] + "***" },
+}
+
+func TestParseMapping(t *testing.T) {
+	mapping, err := ParseMapping([]string{"corp=corp.local", " LAB = lab.corp.local "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"CORP": "corp.local", "LAB": "lab.corp.local"}; !reflect.DeepEqual(mapping, want) {
+		t.Errorf("ParseMapping = %v, want %v", mapping, want)
+	}
+	for _, pair := range []string{"corp", "=corp.local", "CORP="} {
+		if _, err := ParseMapping([]string{pair}); err == nil {
+			t.Errorf("ParseMapping accepted %q", pair)
+		}
+	}
+}
+
+func TestNodeName(t *testing.T) {
+	for username, want := range map[string]string{
+		`CORP\jdoe`:     "JDOE@CORP.LOCAL",
+		`corp\jdoe`:     "JDOE@CORP.LOCAL",
+		`LAB\o'brien`:   "O'BRIEN@LAB",
+		"Administrator": "ADMINISTRATOR",
+	} {
+		if got := exporter.NodeName(username); got != want {
+			t.Errorf("NodeName(%s) = %s, want %s", username, got, want)
+		}
+	}
+}
+
+func TestWriteCypher(t *testing.T) {
+	var buf bytes.Buffer
+	count, err := exporter.WriteCypher(&buf, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`MATCH (u:User {name: 'JDOE@CORP.LOCAL'}) SET u.owned = true, u.password_cracked = true, u.hashtocrack_note = 'Password cracked by HashToCrack: S***';`,
+		`MATCH (u:User {name: 'O\'BRIEN@LAB'}) SET u.owned = true, u.password_cracked = true, u.hashtocrack_note = 'Password cracked by HashToCrack: b***';`,
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); count != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteCypher wrote %d users:\n%s\nwant:\n%s", count, buf.String(), strings.Join(want, "\n"))
+	}
+
+	if got := exporter.UnmappedDomains(entries); !reflect.DeepEqual(got, []string{"LAB"}) {
+		t.Errorf("UnmappedDomains = %v", got)
+	}
+	if got := Domainless(entries); got != 1 {
+		t.Errorf("Domainless = %d, want 1", got)
+	}
+}
+
+func TestWriteIngest(t *testing.T) {
+	var buf bytes.Buffer
+	count, skipped, err := exporter.WriteIngest(&buf, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// LAB has no domain SID
+	if count != 1 || skipped != 1 {
+		t.Fatalf("WriteIngest = %d written, %d skipped", count, skipped)
+	}
+
+	var file ingestFile
+	if err := json.Unmarshal(buf.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Meta.Type != "users" || file.Meta.Count != 1 || len(file.Data) != 1 {
+		t.Fatalf("ingest file: %+v", file)
+	}
+	user := file.Data[0]
+	want := map[string]interface{}{
+		"name":             "JDOE@CORP.LOCAL",
+		"domain":           "CORP.LOCAL",
+		"owned":            true,
+		"password_cracked": true,
+		NoteProperty:       "Password cracked by HashToCrack: S***",
+	}
+	if user.ObjectIdentifier != "S-1-5-21-1-2-3-1101" || !reflect.DeepEqual(user.Properties, want) {
+		t.Errorf("ingest user: %+v", user)
+	}
+}
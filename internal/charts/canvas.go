@@ -0,0 +1,172 @@
+package charts
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Text anchors, matching the SVG text-anchor values
+const (
+	anchorStart  = "start"
+	anchorMiddle = "middle"
+	anchorEnd    = "end"
+)
+
+// canvas is the drawing surface shared by the SVG and PNG renderers, so
+// both outputs come from the same layout code
+type canvas interface {
+	rect(x, y, w, h float64, fill color.RGBA, title string)
+	// text draws a line of text with its baseline at y
+	text(x, y float64, s string, scale int, anchor string, fill color.RGBA)
+	// ring draws a slice of a ring between two radii, angles in radians
+	// clockwise from 12 o'clock
+	ring(cx, cy, inner, outer, start, end float64, fill color.RGBA)
+}
+
+// textWidth returns the width of a string in the bitmap font at a scale
+func textWidth(s string, scale int) float64 {
+	return float64(len([]rune(s))*glyphWidth*scale - scale)
+}
+
+// svgText escapes text for SVG, replacing characters XML cannot carry
+func svgText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0xFFFE || r == 0xFFFF {
+			return '\uFFFD'
+		}
+		return r
+	}, strings.ToValidUTF8(s, "\uFFFD"))
+	return html.EscapeString(s)
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// svgCanvas collects SVG elements
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func newSVGCanvas(width, height int, title string) *svgCanvas {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" role="img" aria-label="%s">`+"\n",
+		width, height, width, height, svgText(title))
+	fmt.Fprintf(&c.b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", width, height)
+	return c
+}
+
+func (c *svgCanvas) rect(x, y, w, h float64, fill color.RGBA, title string) {
+	if title == "" {
+		fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, hexColor(fill))
+		return
+	}
+	fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s</title></rect>`+"\n",
+		x, y, w, h, hexColor(fill), svgText(title))
+}
+
+func (c *svgCanvas) text(x, y float64, s string, scale int, anchor string, fill color.RGBA) {
+	// The bitmap font is 7px high per scale unit; 10px per unit is a close
+	// match for a proportional sans-serif font
+	fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" font-family="sans-serif" font-size="%d" text-anchor="%s" fill="%s" xml:space="preserve">%s</text>`+"\n",
+		x, y, scale*10, anchor, hexColor(fill), svgText(s))
+}
+
+func (c *svgCanvas) ring(cx, cy, inner, outer, start, end float64, fill color.RGBA) {
+	if end-start >= 2*math.Pi-1e-9 {
+		// A full ring cannot be drawn as a single arc
+		mid := (inner + outer) / 2
+		fmt.Fprintf(&c.b, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="none" stroke="%s" stroke-width="%.1f"/>`+"\n",
+			cx, cy, mid, hexColor(fill), outer-inner)
+		return
+	}
+	point := func(r, a float64) (float64, float64) {
+		return cx + r*math.Sin(a), cy - r*math.Cos(a)
+	}
+	large := 0
+	if end-start > math.Pi {
+		large = 1
+	}
+	x1, y1 := point(outer, start)
+	x2, y2 := point(outer, end)
+	x3, y3 := point(inner, end)
+	x4, y4 := point(inner, start)
+	fmt.Fprintf(&c.b, `<path d="M %.2f %.2f A %.1f %.1f 0 %d 1 %.2f %.2f L %.2f %.2f A %.1f %.1f 0 %d 0 %.2f %.2f Z" fill="%s"/>`+"\n",
+		x1, y1, outer, outer, large, x2, y2, x3, y3, inner, inner, large, x4, y4, hexColor(fill))
+}
+
+func (c *svgCanvas) String() string {
+	return c.b.String() + "</svg>\n"
+}
+
+// rasterCanvas draws onto an RGBA image
+type rasterCanvas struct {
+	img *image.RGBA
+}
+
+func newRasterCanvas(width, height int) *rasterCanvas {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	return &rasterCanvas{img: img}
+}
+
+func (c *rasterCanvas) rect(x, y, w, h float64, fill color.RGBA, _ string) {
+	x0, y0 := int(math.Round(x)), int(math.Round(y))
+	x1, y1 := int(math.Round(x+w)), int(math.Round(y+h))
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			c.img.SetRGBA(px, py, fill)
+		}
+	}
+}
+
+func (c *rasterCanvas) text(x, y float64, s string, scale int, anchor string, fill color.RGBA) {
+	switch anchor {
+	case anchorMiddle:
+		x -= textWidth(s, scale) / 2
+	case anchorEnd:
+		x -= textWidth(s, scale)
+	}
+	left, top := int(math.Round(x)), int(math.Round(y))-glyphHeight*scale
+	for i, r := range []rune(s) {
+		bitmap := glyph(r)
+		gx := left + i*glyphWidth*scale
+		for row, bits := range bitmap {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>col) == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						c.img.SetRGBA(gx+col*scale+dx, top+row*scale+dy, fill)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (c *rasterCanvas) ring(cx, cy, inner, outer, start, end float64, fill color.RGBA) {
+	for py := int(cy - outer); py <= int(cy+outer); py++ {
+		for px := int(cx - outer); px <= int(cx+outer); px++ {
+			dx, dy := float64(px)+0.5-cx, float64(py)+0.5-cy
+			dist := math.Hypot(dx, dy)
+			if dist < inner || dist > outer {
+				continue
+			}
+			angle := math.Atan2(dx, -dy)
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+			if angle >= start && angle < end {
+				c.img.SetRGBA(px, py, fill)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package charts
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// Chart colors
+var (
+	Blue   = color.RGBA{0x25, 0x63, 0xEB, 0xFF}
+	Green  = color.RGBA{0x16, 0xA3, 0x4A, 0xFF}
+	Red    = color.RGBA{0xDC, 0x26, 0x26, 0xFF}
+	Amber  = color.RGBA{0xD9, 0x77, 0x06, 0xFF}
+	Purple = color.RGBA{0x7C, 0x3A, 0xED, 0xFF}
+
+	textColor = color.RGBA{0x11, 0x18, 0x27, 0xFF}
+	mutedText = color.RGBA{0x4B, 0x55, 0x63, 0xFF}
+	gridColor = color.RGBA{0xE5, 0xE7, 0xEB, 0xFF}
+)
+
+// Item is a labeled value of a chart
+type Item struct {
+	Label string
+	Value int
+}
+
+// Chart renders to SVG and to a raster image from the same layout
+type Chart interface {
+	Size() (width, height int)
+	draw(c canvas)
+	title() string
+}
+
+// SVG returns the chart as a standalone SVG document
+func SVG(chart Chart) string {
+	width, height := chart.Size()
+	c := newSVGCanvas(width, height, chart.title())
+	chart.draw(c)
+	return c.String()
+}
+
+// Image rasterizes the chart
+func Image(chart Chart) image.Image {
+	c := newRasterCanvas(chart.Size())
+	chart.draw(c)
+	return c.img
+}
+
+// WriteSVG writes the chart as an SVG document
+func WriteSVG(w io.Writer, chart Chart) error {
+	_, err := io.WriteString(w, SVG(chart))
+	return err
+}
+
+// WritePNG writes the chart as a PNG image
+func WritePNG(w io.Writer, chart Chart) error {
+	return png.Encode(w, Image(chart))
+}
+
+const (
+	chartWidth = 640
+	margin     = 24
+	titleSpace = 48
+)
+
+func drawTitle(c canvas, title string) {
+	c.text(margin, 32, title, 2, anchorStart, textColor)
+}
+
+// ColumnChart is a vertical bar chart, e.g. a length distribution
+type ColumnChart struct {
+	Title string
+	Items []Item
+	Color color.RGBA
+}
+
+func (ch *ColumnChart) title() string { return ch.Title }
+
+// Size returns the chart dimensions in pixels
+func (ch *ColumnChart) Size() (int, int) {
+	return chartWidth, 320
+}
+
+func (ch *ColumnChart) draw(c canvas) {
+	drawTitle(c, ch.Title)
+	width, height := ch.Size()
+	if len(ch.Items) == 0 {
+		c.text(float64(width)/2, float64(height)/2, "No data", 2, anchorMiddle, mutedText)
+		return
+	}
+
+	maxValue := maxItem(ch.Items)
+	plotTop, plotBottom := float64(titleSpace+16), float64(height-32)
+	plotHeight := plotBottom - plotTop
+	slot := float64(width-2*margin) / float64(len(ch.Items))
+	barWidth := math.Min(math.Max(slot*0.7, 1), 56)
+
+	c.rect(margin, plotBottom, float64(width-2*margin), 1, gridColor, "")
+	for i, item := range ch.Items {
+		h := float64(item.Value) / float64(maxValue) * plotHeight
+		x := margin + float64(i)*slot + (slot-barWidth)/2
+		c.rect(x, plotBottom-h, barWidth, h, ch.Color, fmt.Sprintf("%s: %d", item.Label, item.Value))
+		c.text(x+barWidth/2, plotBottom-h-4, fmt.Sprint(item.Value), 1, anchorMiddle, mutedText)
+		c.text(x+barWidth/2, plotBottom+16, item.Label, 1, anchorMiddle, textColor)
+	}
+}
+
+// BarChart is a horizontal bar chart of ranked items, e.g. top passwords
+type BarChart struct {
+	Title string
+	Items []Item
+	Color color.RGBA
+}
+
+const (
+	barRow        = 26
+	barLabelRunes = 32
+)
+
+func (ch *BarChart) title() string { return ch.Title }
+
+// Size returns the chart dimensions in pixels
+func (ch *BarChart) Size() (int, int) {
+	rows := max(len(ch.Items), 1)
+	return chartWidth, titleSpace + rows*barRow + margin
+}
+
+func (ch *BarChart) draw(c canvas) {
+	drawTitle(c, ch.Title)
+	width, _ := ch.Size()
+	if len(ch.Items) == 0 {
+		c.text(float64(width)/2, titleSpace+16, "No data", 2, anchorMiddle, mutedText)
+		return
+	}
+
+	maxValue := maxItem(ch.Items)
+	labelWidth := float64(barLabelRunes*glyphWidth) + 12
+	plotLeft := margin + labelWidth
+	plotWidth := float64(width) - plotLeft - margin - 40
+	for i, item := range ch.Items {
+		y := float64(titleSpace + i*barRow)
+		w := math.Max(float64(item.Value)/float64(maxValue)*plotWidth, 1)
+		c.text(plotLeft-8, y+15, utils.Truncate(item.Label, barLabelRunes), 1, anchorEnd, textColor)
+		c.rect(plotLeft, y+4, w, barRow-8, ch.Color, fmt.Sprintf("%s: %d", item.Label, item.Value))
+		c.text(plotLeft+w+6, y+15, fmt.Sprint(item.Value), 1, anchorStart, mutedText)
+	}
+}
+
+// DonutChart shows the share of each item in a total, with a legend
+type DonutChart struct {
+	Title  string
+	Items  []Item
+	Colors []color.RGBA
+}
+
+func (ch *DonutChart) title() string { return ch.Title }
+
+// Size returns the chart dimensions in pixels
+func (ch *DonutChart) Size() (int, int) {
+	return chartWidth, 300
+}
+
+func (ch *DonutChart) color(i int) color.RGBA {
+	if len(ch.Colors) == 0 {
+		return Blue
+	}
+	return ch.Colors[i%len(ch.Colors)]
+}
+
+func (ch *DonutChart) draw(c canvas) {
+	drawTitle(c, ch.Title)
+	total := 0
+	for _, item := range ch.Items {
+		total += item.Value
+	}
+
+	const cx, cy, outer, inner = 160.0, 170.0, 100.0, 60.0
+	if total == 0 {
+		c.ring(cx, cy, inner, outer, 0, 2*math.Pi, gridColor)
+		c.text(cx, cy+5, "No data", 1, anchorMiddle, mutedText)
+		return
+	}
+
+	start := 0.0
+	for i, item := range ch.Items {
+		end := start + float64(item.Value)/float64(total)*2*math.Pi
+		if item.Value > 0 {
+			c.ring(cx, cy, inner, outer, start, end, ch.color(i))
+		}
+		start = end
+
+		y := float64(110 + i*32)
+		c.rect(320, y-12, 16, 16, ch.color(i), "")
+		c.text(346, y, fmt.Sprintf("%s: %d (%.1f%%)", item.Label, item.Value,
+			float64(item.Value)/float64(total)*100), 1, anchorStart, textColor)
+	}
+	c.text(cx, cy+7, fmt.Sprint(total), 2, anchorMiddle, textColor)
+}
+
+func maxItem(items []Item) int {
+	maxValue := 1
+	for _, item := range items {
+		if item.Value > maxValue {
+			maxValue = item.Value
+		}
+	}
+	return maxValue
+}
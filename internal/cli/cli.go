@@ -3,26 +3,97 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/fisher0x/hashtocrack/internal/anonymize"
+	"github.com/fisher0x/hashtocrack/internal/bloodhound"
+	"github.com/fisher0x/hashtocrack/internal/hashcat"
 	"github.com/fisher0x/hashtocrack/internal/modes"
 	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/predict"
+	"github.com/fisher0x/hashtocrack/internal/redact"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+	"github.com/fisher0x/hashtocrack/internal/wordlist"
 )
 
 // Options holds all parsed command-line flags
 type Options struct {
-	NTDSFile  string
-	CrackFile string
-	OutFile   string
-	Disabled  bool
-	Machines  bool
-	PassPol   bool
-	Report    bool
+	NTDSFile     string   `json:"ntds_file"`
+	CrackFile    string   `json:"crack_file"`
+	OutFile      string   `json:"out_file"`
+	Disabled     bool     `json:"disabled"`
+	Machines     bool     `json:"machines"`
+	PassPol      bool     `json:"passpol"`
+	Report       bool     `json:"report"`
+	Pairs        bool     `json:"pairs"`
+	PairPatterns []string `json:"pair_patterns"`
+	PairDistance int      `json:"pair_distance"`
+	GroupFiles   []string `json:"group_files"`
+	PrivGroups   []string `json:"priv_groups"`
+	BHCypher     string   `json:"bh_cypher"`
+	BHJSON       string   `json:"bh_json"`
+	BHDomains    []string `json:"bh_domains"`
+	BHSIDs       []string `json:"bh_sids"`
+	Format       string   `json:"format"`
+	Template     string   `json:"template"`
+	ChartsDir    string   `json:"charts_dir"`
+	Secret       string   `json:"secret,omitempty"`
+	SecretFile   string   `json:"secret_file"`
+	PasswordMode string   `json:"password_mode"`
+	Redact       string   `json:"redact"`
+	RedactMin    int      `json:"redact_min"`
+	MinShare     int      `json:"min_share"`
+	// Encryption of outputs and decryption of inputs
+	Encrypt        bool     `json:"encrypt"`
+	PassphraseFile string   `json:"passphrase_file"`
+	Recipients     []string `json:"recipients"`
+	Identities     []string `json:"identities"`
+	// Evidence manifest
+	Manifest   string `json:"manifest"`
+	NoManifest bool   `json:"no_manifest"`
+	Operator   string `json:"operator"`
+	// Configuration file and profile the options were merged from
+	Config  string `json:"config"`
+	Profile string `json:"profile"`
+	// Workspace the run reads its inputs from and writes its output to
+	Workspace string `json:"workspace"`
+	// Following the potfile in match mode
+	Watch         bool          `json:"watch"`
+	WatchInterval time.Duration `json:"watch_interval"`
+	// Attack plan run by the crack command
+	Plan     string `json:"plan"`
+	Hashcat  string `json:"hashcat"`
+	HashFile string `json:"hash_file"`
+	CrackLog string `json:"crack_log"`
+	// Candidate generation of the wordlist command
+	Keywords  []string `json:"keywords"`
+	Years     int      `json:"years"`
+	MinLength int      `json:"min_length"`
+	Limit     int      `json:"limit"`
+	Check     string   `json:"check"`
+	// Inputs are the values of a command's variadic argument
+	Inputs []string `json:"inputs,omitempty"`
+	// Hash types kept by potmerge
+	PotTypes []string `json:"pot_types"`
+	// Potfiles whose cracked hashes extract leaves out
+	ExcludePot []string `json:"exclude_pot"`
+	// Password predictions of the predict command
+	Direction  string `json:"direction"`
+	Steps      int    `json:"steps"`
+	PerAccount bool   `json:"per_account"`
 }
 
-// ParseArgs parses command-line arguments and returns Options
+// newOptions returns the options with their default values
+func newOptions() *Options {
+	return &Options{WatchInterval: modes.DefaultWatchInterval, Hashcat: "hashcat", Years: wordlist.DefaultYears, Direction: predict.DirectionBoth, Steps: 1, PairDistance: 2, Format: modes.FormatText, PasswordMode: anonymize.PasswordsMask, RedactMin: redact.DefaultMinLength}
+}
+
+// ParseArgs parses the arguments of the deprecated positional form and
+// returns Options. Unknown flags are ignored; commands parse their own flags.
 func ParseArgs(args []string) *Options {
-	opts := &Options{}
+	opts := newOptions()
 
 	if len(args) == 0 {
 		return opts
@@ -42,6 +113,137 @@ func ParseArgs(args []string) *Options {
 			opts.PassPol = true
 		case "-report", "--report":
 			opts.Report = true
+		case "-pairs", "--pairs":
+			opts.Pairs = true
+		case "-pair-patterns", "--pair-patterns":
+			if i+1 < len(args) {
+				opts.PairPatterns = splitList(args[i+1])
+				i++
+			}
+		case "-pair-distance", "--pair-distance":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid -pair-distance '%s'\n", args[i+1])
+					os.Exit(1)
+				}
+				opts.PairDistance = n
+				i++
+			}
+		case "-groups", "--groups":
+			if i+1 < len(args) {
+				opts.GroupFiles = splitList(args[i+1])
+				i++
+			}
+		case "-priv-groups", "--priv-groups":
+			if i+1 < len(args) {
+				opts.PrivGroups = splitList(args[i+1])
+				i++
+			}
+		case "-bh-cypher", "--bh-cypher":
+			if i+1 < len(args) {
+				opts.BHCypher = args[i+1]
+				i++
+			}
+		case "-bh-json", "--bh-json":
+			if i+1 < len(args) {
+				opts.BHJSON = args[i+1]
+				i++
+			}
+		case "-bh-domains", "--bh-domains":
+			if i+1 < len(args) {
+				opts.BHDomains = splitList(args[i+1])
+				i++
+			}
+		case "-bh-sids", "--bh-sids":
+			if i+1 < len(args) {
+				opts.BHSIDs = splitList(args[i+1])
+				i++
+			}
+		case "-format", "--format":
+			if i+1 < len(args) {
+				opts.Format = normalizeFormat(args[i+1])
+				i++
+			}
+		case "-template", "--template":
+			if i+1 < len(args) {
+				opts.Template = args[i+1]
+				i++
+			}
+		case "-charts", "--charts":
+			if i+1 < len(args) {
+				opts.ChartsDir = args[i+1]
+				i++
+			}
+		case "-secret", "--secret":
+			if i+1 < len(args) {
+				opts.Secret = args[i+1]
+				i++
+			}
+		case "-secret-file", "--secret-file":
+			if i+1 < len(args) {
+				opts.SecretFile = args[i+1]
+				i++
+			}
+		case "-passwords", "--passwords":
+			if i+1 < len(args) {
+				opts.PasswordMode = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-redact", "--redact":
+			if i+1 < len(args) {
+				opts.Redact = args[i+1]
+				i++
+			}
+		case "-redact-min", "--redact-min":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid -redact-min '%s'\n", args[i+1])
+					os.Exit(1)
+				}
+				opts.RedactMin = n
+				i++
+			}
+		case "-min-share", "--min-share":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fmt.Fprintf(os.Stderr, "Error: invalid -min-share '%s'\n", args[i+1])
+					os.Exit(1)
+				}
+				opts.MinShare = n
+				i++
+			}
+		case "-encrypt", "--encrypt":
+			opts.Encrypt = true
+		case "-passphrase-file", "--passphrase-file":
+			if i+1 < len(args) {
+				opts.PassphraseFile = args[i+1]
+				i++
+			}
+		case "-recipient", "--recipient":
+			if i+1 < len(args) {
+				opts.Recipients = append(opts.Recipients, splitList(args[i+1])...)
+				i++
+			}
+		case "-identity", "--identity":
+			if i+1 < len(args) {
+				opts.Identities = append(opts.Identities, splitList(args[i+1])...)
+				i++
+			}
+		case "-manifest", "--manifest":
+			if i+1 < len(args) {
+				opts.Manifest = args[i+1]
+				i++
+			}
+		case "-no-manifest", "--no-manifest":
+			opts.NoManifest = true
+		case "-operator", "--operator":
+			if i+1 < len(args) {
+				opts.Operator = args[i+1]
+				i++
+			}
 		case "-o", "-outfile", "--outfile":
 			if i+1 < len(args) {
 				opts.OutFile = args[i+1]
@@ -59,33 +261,350 @@ func ParseArgs(args []string) *Options {
 	return opts
 }
 
-// Run executes the appropriate mode based on parsed options
-func Run(opts *Options) {
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadSecret returns the secret given with -secret or read from -secret-file
+func loadSecret(opts *Options) ([]byte, error) {
+	if opts.SecretFile == "" {
+		return []byte(opts.Secret), nil
+	}
+	data, err := os.ReadFile(opts.SecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret file: %v", err)
+	}
+	return []byte(strings.TrimRight(string(data), "\r\n")), nil
+}
+
+// passwordRedactor builds the redactor selected by -report and -redact, or
+// nil when passwords are shown in clear
+func passwordRedactor(opts *Options) *redact.Redactor {
+	if !opts.Report && opts.Redact == "" {
+		return nil
+	}
+	spec := opts.Redact
+	if spec == "" {
+		spec = redact.First + ":3"
+	}
+
+	secret, err := loadSecret(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	redactor, err := redact.Parse(spec, opts.RedactMin, secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -redact: %v\n", err)
+		os.Exit(1)
+	}
+	return redactor
+}
+
+// analyticsOptions builds the analytics mode settings from parsed options
+func analyticsOptions(opts *Options) modes.AnalyticsOptions {
+	return modes.AnalyticsOptions{
+		InputFile:       opts.NTDSFile,
+		OutFile:         opts.OutFile,
+		IncludeDisabled: opts.Disabled,
+		IncludeMachines: opts.Machines,
+		ShowPasspol:     opts.PassPol,
+		Redactor:        passwordRedactor(opts),
+		ShowPairs:       opts.Pairs,
+		PairPatterns:    opts.PairPatterns,
+		PairMaxDistance: opts.PairDistance,
+		GroupFiles:      opts.GroupFiles,
+		ExtraPrivileged: opts.PrivGroups,
+		MinShare:        opts.MinShare,
+		Format:          opts.Format,
+		TemplateFile:    opts.Template,
+		ChartsDir:       opts.ChartsDir,
+	}
+}
+
+// matchOptions builds the match mode settings from parsed options
+func matchOptions(opts *Options) modes.MatchOptions {
+	domains, err := bloodhound.ParseMapping(opts.BHDomains)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -bh-domains: %v\n", err)
+		os.Exit(1)
+	}
+	sids, err := bloodhound.ParseMapping(opts.BHSIDs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -bh-sids: %v\n", err)
+		os.Exit(1)
+	}
+
+	return modes.MatchOptions{
+		NTDSFile:         opts.NTDSFile,
+		CrackFile:        opts.CrackFile,
+		OutFile:          opts.OutFile,
+		IncludeDisabled:  opts.Disabled,
+		IncludeMachines:  opts.Machines,
+		Format:           opts.Format,
+		Redactor:         passwordRedactor(opts),
+		BloodHoundCypher: opts.BHCypher,
+		BloodHoundJSON:   opts.BHJSON,
+		Domains:          domains,
+		DomainSIDs:       sids,
+		Watch:            opts.Watch,
+		WatchInterval:    opts.WatchInterval,
+		GroupFiles:       opts.GroupFiles,
+		ExtraPrivileged:  opts.PrivGroups,
+	}
+}
+
+// extractOptions builds the extract mode settings from parsed options
+func extractOptions(opts *Options) modes.ExtractOptions {
+	return modes.ExtractOptions{
+		NTDSFile:        opts.NTDSFile,
+		OutFile:         opts.OutFile,
+		IncludeDisabled: opts.Disabled,
+		IncludeMachines: opts.Machines,
+		Format:          opts.Format,
+		ExcludePotfiles: opts.ExcludePot,
+	}
+}
+
+// Output formats supported by each mode
+var (
+	recordFormats  = []string{modes.FormatText, modes.FormatJSON, modes.FormatJSONL}
+	extractFormats = []string{modes.FormatText, modes.FormatUnique, modes.FormatUsername, modes.FormatJohn, modes.FormatPwdump, modes.FormatLMNT, modes.FormatJSON, modes.FormatJSONL}
+	matchFormats   = []string{modes.FormatText, modes.FormatJSON, modes.FormatJSONL, modes.FormatXLSX}
+	reportFormats  = []string{modes.FormatText, modes.FormatJSON, modes.FormatJSONL, modes.FormatHTML, modes.FormatMarkdown, modes.FormatAsciiDoc, modes.FormatXLSX}
+)
+
+// normalizeFormat lowercases a format name and resolves short aliases
+func normalizeFormat(format string) string {
+	format = strings.ToLower(format)
+	switch format {
+	case "md":
+		return modes.FormatMarkdown
+	case "adoc":
+		return modes.FormatAsciiDoc
+	}
+	return format
+}
+
+// checkFormat exits with an error if the format is not supported by the mode
+func checkFormat(format, mode string, allowed []string) {
+	for _, f := range allowed {
+		if format == f {
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Error: unknown format '%s' for %s mode (expected %s)\n", format, mode, strings.Join(allowed, ", "))
+	os.Exit(1)
+}
+
+// checkBinaryOutput exits with an error if a binary format or encrypted
+// output would be written to stdout
+func checkBinaryOutput(opts *Options) {
+	if opts.Format == modes.FormatXLSX && opts.OutFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: -format xlsx requires an output file (-o)\n")
+		os.Exit(1)
+	}
+	if seal.Sealing() && opts.OutFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: encrypted output requires an output file (-o)\n")
+		os.Exit(1)
+	}
+}
+
+// Run executes the mode detected from the positional arguments and file
+// content. Deprecated: use the extract, match and analyze commands.
+func Run(opts *Options, version string) {
 	if opts.NTDSFile == "" {
 		PrintUsage()
 		os.Exit(1)
 	}
 
+	configureKeys(opts)
+	checkBinaryOutput(opts)
+
 	// Determine mode
 	if opts.CrackFile != "" {
 		// Check if crackfile exists
 		if _, err := os.Stat(opts.CrackFile); err == nil {
 			// Mode 2: Match mode (ntdsfile + crackfile)
-			modes.RunMatch(opts.NTDSFile, opts.CrackFile, opts.OutFile, opts.Disabled, opts.Machines)
+			runMatch(opts, version)
 		} else {
 			fmt.Fprintf(os.Stderr, "Error: File '%s' not found\n", opts.CrackFile)
 			os.Exit(1)
 		}
-	} else if opts.PassPol {
-		// Mode 3: Analytics mode (detected by -passpol flag)
-		modes.RunAnalytics(opts.NTDSFile, opts.OutFile, opts.Disabled, opts.Machines, opts.PassPol, opts.Report)
+	} else if opts.PassPol || opts.Pairs || ntds.IsAnalyticsFile(opts.NTDSFile) {
+		// Mode 3: Analytics mode (detected by -passpol or -pairs flag, or by
+		// the file content)
+		runAnalytics(opts, version)
 	} else {
-		// Check file content to determine if it's analytics or extract mode
-		if ntds.IsAnalyticsFile(opts.NTDSFile) {
-			modes.RunAnalytics(opts.NTDSFile, opts.OutFile, opts.Disabled, opts.Machines, opts.PassPol, opts.Report)
-		} else {
-			// Mode 1: Extract hashes mode
-			modes.RunExtract(opts.NTDSFile, opts.OutFile, opts.Disabled, opts.Machines)
-		}
+		// Mode 1: Extract hashes mode
+		runExtract(opts, version)
+	}
+}
+
+// runExtract runs extract mode with its evidence manifest
+func runExtract(opts *Options, version string) {
+	checkFormat(opts.Format, "extract", extractFormats)
+	m := startManifest(opts, "extract", version)
+	recordInputs(m, "ntds", opts.NTDSFile)
+	recordInputs(m, "potfile", opts.ExcludePot...)
+	extractOpts := extractOptions(opts)
+	extractOpts.Manifest = m
+	modes.RunExtract(extractOpts)
+	finishManifest(m, opts)
+}
+
+// runMatch runs match mode with its evidence manifest
+func runMatch(opts *Options, version string) {
+	checkFormat(opts.Format, "match", matchFormats)
+	m := startManifest(opts, "match", version)
+	recordInputs(m, "ntds", opts.NTDSFile)
+	recordInputs(m, "potfile", opts.CrackFile)
+	matchOpts := matchOptions(opts)
+	matchOpts.Manifest = m
+	modes.RunMatch(matchOpts)
+	finishManifest(m, opts)
+}
+
+// runCrack runs the attack plan of the crack command with its evidence
+// manifest. The wordlists and rules are not hashed as they can be huge.
+func runCrack(opts *Options, version string) {
+	checkFormat(opts.Format, "crack", matchFormats)
+	if opts.Plan == "" {
+		fmt.Fprintf(os.Stderr, "Error: crack requires an attack plan (-plan)\n")
+		os.Exit(1)
+	}
+	if opts.OutFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: crack requires an output file (-o)\n")
+		os.Exit(1)
+	}
+	plan, err := hashcat.LoadPlan(opts.Plan)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading attack plan: %v\n", err)
+		os.Exit(1)
+	}
+
+	m := startManifest(opts, "crack", version)
+	recordInputs(m, "ntds", opts.NTDSFile)
+	recordInputs(m, "plan", opts.Plan)
+	matchOpts := matchOptions(opts)
+	matchOpts.Manifest = m
+	modes.RunCrack(modes.CrackOptions{
+		Plan:     plan,
+		Hashcat:  opts.Hashcat,
+		HashFile: opts.HashFile,
+		LogFile:  opts.CrackLog,
+		Match:    matchOpts,
+	})
+	finishManifest(m, opts)
+}
+
+// runWordlist runs the wordlist command with its evidence manifest
+func runWordlist(opts *Options, version string) {
+	m := startManifest(opts, "wordlist", version)
+	recordInputs(m, "ntds", opts.NTDSFile)
+	recordInputs(m, "keywords", opts.Keywords...)
+	modes.RunWordlist(modes.WordlistOptions{
+		NTDSFile:        opts.NTDSFile,
+		OutFile:         opts.OutFile,
+		IncludeDisabled: opts.Disabled,
+		IncludeMachines: opts.Machines,
+		KeywordFiles:    opts.Keywords,
+		Years:           opts.Years,
+		MinLength:       opts.MinLength,
+		Limit:           opts.Limit,
+		CheckPotfile:    opts.Check,
+		Manifest:        m,
+	})
+	finishManifest(m, opts)
+}
+
+// runPredict runs the predict command with its evidence manifest
+func runPredict(opts *Options, version string) {
+	checkFormat(opts.Format, "predict", recordFormats)
+	m := startManifest(opts, "predict", version)
+	recordInputs(m, "matched", opts.NTDSFile)
+	modes.RunPredict(modes.PredictOptions{
+		InputFile:       opts.NTDSFile,
+		OutFile:         opts.OutFile,
+		IncludeDisabled: opts.Disabled,
+		IncludeMachines: opts.Machines,
+		Format:          opts.Format,
+		Direction:       opts.Direction,
+		Steps:           opts.Steps,
+		PerAccount:      opts.PerAccount,
+		Manifest:        m,
+	})
+	finishManifest(m, opts)
+}
+
+// runPotmerge runs the potmerge command with its evidence manifest
+func runPotmerge(opts *Options, version string) {
+	m := startManifest(opts, "potmerge", version)
+	recordInputs(m, "potfile", opts.Inputs...)
+	modes.RunPotmerge(modes.PotmergeOptions{
+		Potfiles: opts.Inputs,
+		OutFile:  opts.OutFile,
+		Types:    opts.PotTypes,
+		Manifest: m,
+	})
+	finishManifest(m, opts)
+}
+
+// runAnalytics runs analytics mode with its evidence manifest
+func runAnalytics(opts *Options, version string) {
+	checkFormat(opts.Format, "analytics", reportFormats)
+	m := startManifest(opts, "analyze", version)
+	recordInputs(m, "matched", opts.NTDSFile)
+	recordInputs(m, "groups", opts.GroupFiles...)
+	if opts.Template != "" {
+		recordInputs(m, "template", opts.Template)
+	}
+	analyticsOpts := analyticsOptions(opts)
+	analyticsOpts.Manifest = m
+	modes.RunAnalytics(analyticsOpts)
+	finishManifest(m, opts)
+}
+
+// RunAnonymize executes the anonymize command
+func RunAnonymize(opts *Options, version string) {
+	if opts.NTDSFile == "" {
+		fmt.Fprintf(os.Stderr, "Usage: HashToCrack anonymize <matchedfile> -secret-file <file> [-passwords mask|hash] [-o <outfile>]\n")
+		os.Exit(1)
 	}
+	if opts.PasswordMode != anonymize.PasswordsMask && opts.PasswordMode != anonymize.PasswordsHash {
+		fmt.Fprintf(os.Stderr, "Error: unknown -passwords mode '%s' (expected %s or %s)\n", opts.PasswordMode, anonymize.PasswordsMask, anonymize.PasswordsHash)
+		os.Exit(1)
+	}
+
+	configureKeys(opts)
+	checkBinaryOutput(opts)
+
+	secret, err := loadSecret(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(secret) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: anonymize requires a per-engagement secret (-secret or -secret-file)\n")
+		os.Exit(1)
+	}
+
+	m := startManifest(opts, "anonymize", version)
+	recordInputs(m, "matched", opts.NTDSFile)
+	modes.RunAnonymize(modes.AnonymizeOptions{
+		InputFile:    opts.NTDSFile,
+		OutFile:      opts.OutFile,
+		Secret:       secret,
+		PasswordMode: opts.PasswordMode,
+		PairPatterns: opts.PairPatterns,
+		Manifest:     m,
+	})
+	finishManifest(m, opts)
 }
@@ -0,0 +1,593 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fisher0x/hashtocrack/internal/modes"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/predict"
+	"github.com/fisher0x/hashtocrack/internal/workspace"
+)
+
+// command is a subcommand with its own flag set
+type command struct {
+	name    string
+	aliases []string
+	// args names the positional arguments, bound in order to NTDSFile and
+	// CrackFile
+	args []string
+	// inputs names the workspace role of each argument; arguments left out
+	// inside a workspace are the latest file of their role
+	inputs []string
+	// output is the workspace role of the output file
+	output string
+	// summary is the one-line description listed in the help
+	summary string
+	// details follow the summary in the command's own help
+	details string
+	flags   []flagGroup
+	run     func(opts *Options, version string)
+	// synopsis replaces the usage line built from args
+	synopsis string
+	// dispatch handles the arguments of commands with their own actions,
+	// in place of run
+	dispatch func(args []string, version string)
+}
+
+// flagGroup registers a group of related flags on a flag set
+type flagGroup func(fs *flag.FlagSet, opts *Options)
+
+// commands lists the subcommands in the order shown in the help
+var commands = []*command{
+	{
+		name:    "extract",
+		args:    []string{"ntdsfile"},
+		inputs:  []string{workspace.RoleNTDS},
+		output:  workspace.RoleHashes,
+		summary: "Extract NT hashes from an NTDS file for cracking.",
+		flags:   []flagGroup{filterFlags, outputFlags(extractFormats), extractFlags, keyFlags, manifestFlags, workspaceFlags, configFlags},
+		run: func(opts *Options, version string) {
+			prepareOutput(opts)
+			runExtract(opts, version)
+		},
+	},
+	{
+		name:    "match",
+		args:    []string{"ntdsfile", "potfile"},
+		inputs:  []string{workspace.RoleNTDS, workspace.RolePotfile},
+		output:  workspace.RoleMatched,
+		summary: "Match NTDS accounts with the cracked passwords of a hashcat potfile.",
+		flags:   []flagGroup{filterFlags, outputFlags(matchFormats), redactFlags, bloodhoundFlags, watchFlags, keyFlags, manifestFlags, workspaceFlags, configFlags},
+		run: func(opts *Options, version string) {
+			prepareOutput(opts)
+			runMatch(opts, version)
+		},
+	},
+	{
+		name:    "crack",
+		args:    []string{"ntdsfile", "potfile"},
+		inputs:  []string{workspace.RoleNTDS, workspace.RolePotfile},
+		output:  workspace.RoleMatched,
+		summary: "Run a hashcat attack plan against the NT hashes, matching after each stage.",
+		flags:   []flagGroup{filterFlags, outputFlags(matchFormats), redactFlags, bloodhoundFlags, crackFlags, decryptFlags, manifestFlags, workspaceFlags, configFlags},
+		run: func(opts *Options, version string) {
+			prepareOutput(opts)
+			runCrack(opts, version)
+		},
+	},
+	{
+		name:    "wordlist",
+		args:    []string{"ntdsfile"},
+		inputs:  []string{workspace.RoleNTDS},
+		output:  workspace.RoleWordlist,
+		summary: "Build an engagement-specific wordlist from the account and domain names, keywords and dates.",
+		flags:   []flagGroup{filterFlags, outFlag, wordlistFlags, decryptFlags, manifestFlags, workspaceFlags, configFlags},
+		run: func(opts *Options, version string) {
+			configureKeys(opts)
+			runWordlist(opts, version)
+		},
+	},
+	{
+		name:    "predict",
+		args:    []string{"matchedfile"},
+		inputs:  []string{workspace.RoleMatched},
+		output:  workspace.RoleWordlist,
+		summary: "Predict the next and previous passwords of cracked accounts (numbers, seasons, months, quarters, years).",
+		flags:   []flagGroup{filterFlags, outputFlags(recordFormats), predictFlags, keyFlags, manifestFlags, workspaceFlags, configFlags},
+		run: func(opts *Options, version string) {
+			prepareOutput(opts)
+			runPredict(opts, version)
+		},
+	},
+	{
+		name:    "potmerge",
+		args:    []string{"potfile..."},
+		inputs:  []string{workspace.RolePotfile},
+		output:  workspace.RolePotfile,
+		summary: "Merge potfiles, normalizing and deduplicating entries and resolving conflicts by NT hash.",
+		flags:   []flagGroup{outFlag, potmergeFlags, decryptFlags, manifestFlags, workspaceFlags, configFlags},
+		run: func(opts *Options, version string) {
+			configureKeys(opts)
+			runPotmerge(opts, version)
+		},
+	},
+	{
+		name:    "analyze",
+		aliases: []string{"analytics"},
+		args:    []string{"matchedfile"},
+		inputs:  []string{workspace.RoleMatched},
+		output:  workspace.RoleReport,
+		summary: "Generate password statistics from a matched file.",
+		flags:   []flagGroup{filterFlags, outputFlags(reportFormats), redactFlags, analyticsFlags, keyFlags, manifestFlags, workspaceFlags, configFlags},
+		run: func(opts *Options, version string) {
+			prepareOutput(opts)
+			runAnalytics(opts, version)
+		},
+	},
+	{
+		name:    "anonymize",
+		args:    []string{"matchedfile"},
+		inputs:  []string{workspace.RoleMatched},
+		output:  workspace.RoleAnonymized,
+		summary: "Pseudonymize a matched file for sharing outside the engagement.",
+		flags:   []flagGroup{anonymizeFlags, outFlag, keyFlags, manifestFlags, workspaceFlags, configFlags},
+		run:     RunAnonymize,
+	},
+	{
+		name:    "init",
+		args:    []string{"dir"},
+		summary: "Create an engagement workspace tracking inputs, results, reports and runs.",
+		run:     func(opts *Options, version string) { RunInit(opts) },
+	},
+	{
+		name:    "keygen",
+		args:    []string{"keyfile"},
+		summary: "Create an X25519 key pair for encrypted files and print the public key.",
+		run:     func(opts *Options, version string) { RunKeygen(opts) },
+	},
+	{
+		name:    "encrypt",
+		args:    []string{"file"},
+		summary: "Encrypt a file for -recipient keys or a passphrase.",
+		flags:   []flagGroup{outFlag, keyFlags, manifestFlags, configFlags},
+		run:     RunEncrypt,
+	},
+	{
+		name:    "decrypt",
+		args:    []string{"file"},
+		summary: "Decrypt a file with -identity or a passphrase.",
+		flags:   []flagGroup{outFlag, decryptFlags, manifestFlags, configFlags},
+		run:     RunDecrypt,
+	},
+	{
+		name:    "verify-manifest",
+		args:    []string{"manifest"},
+		summary: "Re-check the SHA-256 of the files recorded in a manifest or in a report's embedded manifest.",
+		flags:   []flagGroup{decryptFlags, configFlags},
+		run:     func(opts *Options, version string) { RunVerifyManifest(opts) },
+	},
+}
+
+func init() {
+	// Registered here as their dispatch functions look up the commands
+	commands = append(commands, &command{
+		name:     "config",
+		synopsis: "HashToCrack config show [<command>] [options]",
+		summary:  "Show the effective settings for one command or for all of them.",
+		details:  "Settings are merged from the defaults, the configuration file, the environment\nand the flags, in that order.",
+		flags:    []flagGroup{configFlags},
+		dispatch: runConfig,
+	}, &command{
+		name:     "workspace",
+		synopsis: "HashToCrack workspace status | add <role> <file>... [options]",
+		summary:  "Show the latest files and the runs of the workspace, or add files to it.",
+		details:  "Roles: ntds, potfile, hashes, matched, report, anonymized, wordlist.",
+		flags:    []flagGroup{workspaceFlags, configFlags},
+		dispatch: runWorkspace,
+	})
+}
+
+// findCommand returns the command with the given name or alias, or nil
+func findCommand(name string) *command {
+	name = strings.ToLower(name)
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+		for _, alias := range c.aliases {
+			if alias == name {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Execute runs the command named by the first argument. Other arguments fall
+// back to the deprecated positional form, which guesses the mode.
+func Execute(args []string, version string) {
+	if c := findCommand(args[0]); c != nil {
+		if c.dispatch != nil {
+			c.dispatch(args[1:], version)
+		} else {
+			c.execute(args[1:], version)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "[!] Positional mode detection is deprecated; use 'HashToCrack extract|match|analyze' (see 'HashToCrack help')\n")
+	Run(ParseArgs(args), version)
+}
+
+// PrintCommandHelp displays the help of one command, returning false if
+// there is no such command
+func PrintCommandHelp(name string) bool {
+	c := findCommand(name)
+	if c == nil {
+		return false
+	}
+	fs := c.flagSet(newOptions())
+	fs.SetOutput(os.Stdout)
+	c.usage(fs)
+	return true
+}
+
+// flagSet builds the command's flag set, binding the flags to opts
+func (c *command) flagSet(opts *Options) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	for _, group := range c.flags {
+		group(fs, opts)
+	}
+	return fs
+}
+
+// usage prints the command synopsis and its flags
+func (c *command) usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	synopsis := c.synopsis
+	if synopsis == "" {
+		synopsis = "HashToCrack " + c.name
+		for _, arg := range c.args {
+			if name := strings.TrimSuffix(arg, "..."); name != arg {
+				synopsis += " <" + name + ">..."
+			} else {
+				synopsis += " <" + arg + ">"
+			}
+		}
+		if hasFlags {
+			synopsis += " [options]"
+		}
+	}
+
+	fmt.Fprintf(out, "Usage: %s\n\n%s\n", synopsis, c.summary)
+	if c.details != "" {
+		fmt.Fprintf(out, "%s\n", c.details)
+	}
+	if hasFlags {
+		fmt.Fprintf(out, "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+}
+
+// execute resolves the command's options, validates them and runs it
+func (c *command) execute(args []string, version string) {
+	opts, s, positional := c.resolve(args)
+
+	var ws *workspace.Workspace
+	if c.output != "" {
+		ws = openWorkspace(opts)
+	}
+	if ws != nil {
+		positional = c.workspaceInputs(ws, positional)
+	}
+	if len(positional) < len(c.args) {
+		usageError(c, "missing <%s>", c.argName(len(positional)))
+	}
+	if len(positional) > len(c.args) && !c.variadic() {
+		usageError(c, "unexpected argument '%s'", positional[len(c.args)])
+	}
+	if c.variadic() {
+		// The last argument takes the remaining ones
+		opts.Inputs = positional[len(c.args)-1:]
+		positional = positional[:len(c.args)-1]
+	}
+	for i, value := range positional {
+		switch i {
+		case 0:
+			opts.NTDSFile = value
+		case 1:
+			opts.CrackFile = value
+		}
+	}
+
+	if ws != nil && opts.OutFile == "" {
+		opts.OutFile = ws.OutputPath(c.output, c.name, outputExt(opts))
+	}
+
+	if err := validateFlags(s, opts); err != nil {
+		usageError(c, "%v", err)
+	}
+	started := time.Now().UTC()
+	c.run(opts, version)
+	if ws != nil {
+		c.recordRun(ws, opts, started)
+	}
+}
+
+// variadic reports whether the last argument of the command takes one or
+// more values, marked by a trailing "..." in its name
+func (c *command) variadic() bool {
+	return len(c.args) > 0 && strings.HasSuffix(c.args[len(c.args)-1], "...")
+}
+
+// argName returns the name of the i-th argument
+func (c *command) argName(i int) string {
+	return strings.TrimSuffix(c.args[i], "...")
+}
+
+// usageError exits with an error about the command line of a command
+func usageError(c *command, format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Error: %s\n", fmt.Sprintf(format, args...))
+	fmt.Fprintf(os.Stderr, "Run 'HashToCrack %s -h' for usage.\n", c.name)
+	os.Exit(2)
+}
+
+// parse parses flags placed before, between or after the positional
+// arguments, and returns the positional arguments. -h prints the command
+// help and exits.
+func (c *command) parse(fs *flag.FlagSet, args []string) []string {
+	// Errors are reported by usageError rather than the flag package
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err == flag.ErrHelp {
+			fs.SetOutput(os.Stdout)
+			c.usage(fs)
+			os.Exit(0)
+		} else if err != nil {
+			usageError(c, "%v", err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// validateFlags rejects invalid values, and conflicting flags set at the
+// same precedence
+func validateFlags(s *settings, opts *Options) error {
+	switch {
+	case opts.PairDistance < 0:
+		return fmt.Errorf("invalid -pair-distance %d", opts.PairDistance)
+	case opts.RedactMin < 0:
+		return fmt.Errorf("invalid -redact-min %d", opts.RedactMin)
+	case s.origins["min-share"] != "" && opts.MinShare < 1:
+		return fmt.Errorf("invalid -min-share %d (must be at least 1)", opts.MinShare)
+	case s.conflict("o", "outfile"):
+		return fmt.Errorf("-o and -outfile cannot be used together")
+	case s.conflict("secret", "secret-file"):
+		return fmt.Errorf("-secret and -secret-file cannot be used together")
+	case s.conflict("manifest", "no-manifest"):
+		return fmt.Errorf("-manifest and -no-manifest cannot be used together")
+	case s.conflict("operator", "no-manifest"):
+		return fmt.Errorf("-operator and -no-manifest cannot be used together")
+	case opts.Template != "" && opts.Format != modes.FormatText && opts.Format != modes.FormatHTML:
+		return fmt.Errorf("-template renders text or html, not -format %s", opts.Format)
+	case len(opts.BHDomains) > 0 && opts.BHCypher == "" && opts.BHJSON == "":
+		return fmt.Errorf("-bh-domains requires -bh-cypher or -bh-json")
+	case len(opts.BHSIDs) > 0 && opts.BHJSON == "":
+		return fmt.Errorf("-bh-sids requires -bh-json")
+	case opts.Years < 0:
+		return fmt.Errorf("invalid -years %d", opts.Years)
+	case opts.MinLength < 0:
+		return fmt.Errorf("invalid -min-length %d", opts.MinLength)
+	case opts.Limit < 0:
+		return fmt.Errorf("invalid -limit %d", opts.Limit)
+	case invalidPotType(opts.PotTypes) != "":
+		return fmt.Errorf("unknown -type '%s' (expected %s, %s or %s)", invalidPotType(opts.PotTypes), ntds.PotNT, ntds.PotLM, ntds.PotOther)
+	case opts.Steps < 1:
+		return fmt.Errorf("invalid -steps %d (must be at least 1)", opts.Steps)
+	case opts.Direction != predict.DirectionBoth && opts.Direction != predict.DirectionNext && opts.Direction != predict.DirectionPrevious:
+		return fmt.Errorf("unknown -direction '%s' (expected %s, %s or %s)", opts.Direction, predict.DirectionNext, predict.DirectionPrevious, predict.DirectionBoth)
+	case opts.WatchInterval <= 0:
+		return fmt.Errorf("invalid -watch-interval %s", opts.WatchInterval)
+	case opts.Watch && opts.Format != modes.FormatText && opts.Format != modes.FormatJSONL:
+		return fmt.Errorf("-watch appends to text or jsonl output, not -format %s", opts.Format)
+	case opts.Watch && (opts.Encrypt || len(opts.Recipients) > 0):
+		return fmt.Errorf("-watch appends to the output in place and cannot encrypt it")
+	}
+	return nil
+}
+
+// invalidPotType returns the first unknown potfile hash type, if any
+func invalidPotType(types []string) string {
+	for _, t := range types {
+		if t != ntds.PotNT && t != ntds.PotLM && t != ntds.PotOther {
+			return t
+		}
+	}
+	return ""
+}
+
+// prepareOutput loads the encryption keys and checks the output settings
+func prepareOutput(opts *Options) {
+	configureKeys(opts)
+	checkBinaryOutput(opts)
+}
+
+// listFlag is a comma-separated list flag; repeated flags add to the list
+type listFlag struct {
+	items *[]string
+}
+
+func (l listFlag) String() string {
+	if l.items == nil {
+		return ""
+	}
+	return strings.Join(*l.items, ",")
+}
+
+func (l listFlag) Set(value string) error {
+	*l.items = append(*l.items, splitList(value)...)
+	return nil
+}
+
+// formatFlag is an output format, with short aliases resolved
+type formatFlag struct {
+	format *string
+}
+
+func (f formatFlag) String() string {
+	if f.format == nil {
+		return ""
+	}
+	return *f.format
+}
+
+func (f formatFlag) Set(value string) error {
+	*f.format = normalizeFormat(value)
+	return nil
+}
+
+func filterFlags(fs *flag.FlagSet, opts *Options) {
+	fs.BoolVar(&opts.Disabled, "disabled", false, "Include disabled accounts")
+	fs.BoolVar(&opts.Machines, "machines", false, "Include machine accounts (accounts ending with $)")
+}
+
+func outFlag(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.OutFile, "o", "", "Write output to `file` instead of stdout")
+	fs.StringVar(&opts.OutFile, "outfile", "", "Write output to `file` (same as -o)")
+}
+
+// outputFlags registers -o and -format with the formats of a mode
+func outputFlags(formats []string) flagGroup {
+	return func(fs *flag.FlagSet, opts *Options) {
+		outFlag(fs, opts)
+		usage := "Output `format`: " + strings.Join(formats, ", ")
+		for _, format := range formats {
+			if format == modes.FormatXLSX {
+				usage += "\n(xlsx requires -o)"
+			}
+		}
+		fs.Var(formatFlag{&opts.Format}, "format", usage)
+	}
+}
+
+func redactFlags(fs *flag.FlagSet, opts *Options) {
+	fs.BoolVar(&opts.Report, "report", false, "Redact passwords in every output (default: first 3 chars)")
+	fs.StringVar(&opts.Redact, "redact", "", "Redaction `strategy`, implies -report: full, mask, first:N,\nlast:N, ends:N, length, or hash (keyed, -secret)")
+	fs.IntVar(&opts.RedactMin, "redact-min", opts.RedactMin, "Fully mask passwords shorter than `N` characters")
+	fs.StringVar(&opts.Secret, "secret", "", "Per-engagement `secret` keying -redact hash")
+	fs.StringVar(&opts.SecretFile, "secret-file", "", "Read the secret from a `file`")
+}
+
+func analyticsFlags(fs *flag.FlagSet, opts *Options) {
+	fs.BoolVar(&opts.PassPol, "passpol", false, "Show password policy compliance statistics")
+	fs.IntVar(&opts.MinShare, "min-share", 0, "Only disclose passwords used by at least `k` accounts")
+	fs.BoolVar(&opts.Pairs, "pairs", false, "Report admin/user account pairs with reused credentials")
+	fs.Var(listFlag{&opts.PairPatterns}, "pair-patterns", "Comma-separated admin naming `patterns` using {user}")
+	fs.IntVar(&opts.PairDistance, "pair-distance", opts.PairDistance, "Max edit `distance` for similar pair passwords")
+	groupFlags(fs, opts)
+	fs.StringVar(&opts.Template, "template", "", "Render the report with a Go template `file`")
+	fs.StringVar(&opts.ChartsDir, "charts", "", "Write SVG and PNG charts into a `directory`")
+}
+
+func groupFlags(fs *flag.FlagSet, opts *Options) {
+	fs.Var(listFlag{&opts.GroupFiles}, "groups", "Comma-separated group membership `files` (ldapdomaindump,\nSharpHound or group:member text)")
+	fs.Var(listFlag{&opts.PrivGroups}, "priv-groups", "Comma-separated extra `groups` to treat as privileged")
+}
+
+// watchFlags registers -watch with the group files tagging privileged
+// accounts in its status
+func watchFlags(fs *flag.FlagSet, opts *Options) {
+	fs.BoolVar(&opts.Watch, "watch", false, "Follow the potfile and append newly cracked accounts to the output\nuntil interrupted (text or jsonl, not encrypted)")
+	fs.DurationVar(&opts.WatchInterval, "watch-interval", opts.WatchInterval, "Potfile polling `interval` for -watch")
+	groupFlags(fs, opts)
+}
+
+func extractFlags(fs *flag.FlagSet, opts *Options) {
+	fs.Var(listFlag{&opts.ExcludePot}, "exclude-pot", "Comma-separated `potfiles`: leave out the hashes already cracked in them")
+}
+
+// crackFlags registers the attack plan and hashcat settings of crack. The
+// hash list and potfile are read by hashcat, so they are never encrypted.
+func crackFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.Plan, "plan", "", "JSON attack plan `file` (wordlist, rules and mask stages)")
+	fs.StringVar(&opts.Hashcat, "hashcat", opts.Hashcat, "hashcat `executable`")
+	fs.StringVar(&opts.HashFile, "hashes", "", "Keep the extracted hash list in `file` (default: a temporary file)")
+	fs.StringVar(&opts.CrackLog, "log", "", "Write the exit status, time and cracks of every stage to a JSON `file`")
+}
+
+// wordlistFlags registers the candidate generation settings of wordlist.
+// The wordlist and potfile are read by hashcat, so they are never encrypted.
+func wordlistFlags(fs *flag.FlagSet, opts *Options) {
+	fs.Var(listFlag{&opts.Keywords}, "keywords", "Comma-separated keyword `files`, one word per line")
+	fs.IntVar(&opts.Years, "years", opts.Years, "Number of past `years` combined with the words (next year is always included)")
+	fs.IntVar(&opts.MinLength, "min-length", 0, "Drop candidates shorter than `n` characters")
+	fs.IntVar(&opts.Limit, "limit", 0, "Keep the `n` most likely candidates (default: all)")
+	fs.StringVar(&opts.Check, "check", "", "Check the candidates against the NT hashes and append cracks to a `potfile`")
+}
+
+func predictFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.Direction, "direction", opts.Direction, "Predicted passwords: `direction` next, previous or both")
+	fs.IntVar(&opts.Steps, "steps", opts.Steps, "Number of rotations predicted in each `direction`")
+	fs.BoolVar(&opts.PerAccount, "per-account", false, "Write user:password predictions for every account instead of a wordlist")
+}
+
+// potmergeFlags registers the hash type filter of potmerge. The merged
+// potfile is read by hashcat, so it is never encrypted.
+func potmergeFlags(fs *flag.FlagSet, opts *Options) {
+	fs.Var(listFlag{&opts.PotTypes}, "type", "Comma-separated hash `types` to keep: nt, lm, other (default: all)")
+}
+
+func bloodhoundFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.BHCypher, "bh-cypher", "", "Write a Cypher script marking cracked users as owned to `file`")
+	fs.StringVar(&opts.BHJSON, "bh-json", "", "Write a BloodHound CE users.json ingest `file`")
+	fs.Var(listFlag{&opts.BHDomains}, "bh-domains", "NetBIOS to FQDN `mapping` (CORP=corp.local,...)")
+	fs.Var(listFlag{&opts.BHSIDs}, "bh-sids", "NetBIOS to domain SID `mapping` (CORP=S-1-5-21-...)")
+}
+
+func anonymizeFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.Secret, "secret", "", "Per-engagement `secret` keying the pseudonyms")
+	fs.StringVar(&opts.SecretFile, "secret-file", "", "Read the secret from a `file`")
+	fs.StringVar(&opts.PasswordMode, "passwords", opts.PasswordMode, "Password tokens: `mode` mask or hash")
+	fs.Var(listFlag{&opts.PairPatterns}, "pair-patterns", "Comma-separated admin naming `patterns` using {user}")
+}
+
+// decryptFlags registers the flags supplying keys to decrypt inputs
+func decryptFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.PassphraseFile, "passphrase-file", "", "Read the passphrase from a `file` (default: "+PassphraseEnv+")")
+	fs.Var(listFlag{&opts.Identities}, "identity", "Private key `file` decrypting encrypted inputs")
+}
+
+// keyFlags registers the flags encrypting outputs and decrypting inputs
+func keyFlags(fs *flag.FlagSet, opts *Options) {
+	fs.BoolVar(&opts.Encrypt, "encrypt", false, "Encrypt every output file with the passphrase")
+	fs.Var(listFlag{&opts.Recipients}, "recipient", "Encrypt every output file to a htcpub1: public `key` or key file")
+	decryptFlags(fs, opts)
+}
+
+func manifestFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.Manifest, "manifest", "", "Evidence manifest `file` (default: <outfile>.manifest.json with -o)")
+	fs.BoolVar(&opts.NoManifest, "no-manifest", false, "Do not write an evidence manifest")
+	fs.StringVar(&opts.Operator, "operator", "", "Operator `name` recorded in the manifest (default: "+OperatorEnv+",\nthen the login name)")
+}
+
+// printCommands lists the commands with their summaries
+func printCommands(w io.Writer) {
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", c.name, c.summary)
+	}
+}
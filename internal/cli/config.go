@@ -0,0 +1,493 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// ConfigEnv names the environment variable holding the configuration file
+	ConfigEnv = "HASHTOCRACK_CONFIG"
+	// ProfileEnv names the environment variable holding the profile name
+	ProfileEnv = "HASHTOCRACK_PROFILE"
+	// EnvPrefix prefixes the environment variable of every setting flag,
+	// e.g. HASHTOCRACK_FORMAT for -format and HASHTOCRACK_PAIR_PATTERNS for
+	// -pair-patterns
+	EnvPrefix = "HASHTOCRACK_"
+	// ConfigFile is the configuration file looked up in the current
+	// directory, then in the user configuration directory
+	ConfigFile = "hashtocrack.json"
+)
+
+// Sources of a setting, from lowest to highest precedence
+const (
+	sourceDefault = "default"
+	sourceConfig  = "config"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// Config is an engagement configuration file. Sections map flag names to
+// values; a key naming a command holds settings applied to that command only.
+type Config struct {
+	Path     string             `json:"-"`
+	Defaults Section            `json:"defaults"`
+	Profiles map[string]Section `json:"profiles"`
+}
+
+// Section is a set of flag values
+type Section map[string]json.RawMessage
+
+// conflicts pairs flags that cannot be used together. A flag set at a higher
+// precedence replaces its conflicting flag from a lower one.
+var conflicts = [][2]string{
+	{"o", "outfile"},
+	{"secret", "secret-file"},
+	{"manifest", "no-manifest"},
+	{"operator", "no-manifest"},
+}
+
+// runFlags name the outputs and the actions of a single run. They have no
+// environment variable: a variable left set would overwrite the outputs of
+// every following run.
+var runFlags = map[string]bool{
+	"o":         true,
+	"outfile":   true,
+	"manifest":  true,
+	"bh-cypher": true,
+	"bh-json":   true,
+	"charts":    true,
+	"hashes":    true,
+	"log":       true,
+	"check":     true,
+	"watch":     true,
+}
+
+// settings applies the layers of configuration to a flag set and records
+// where each flag value comes from
+type settings struct {
+	fs *flag.FlagSet
+	// layer is the source of the values being set
+	layer   string
+	origins map[string]string
+}
+
+// newSettings wraps every flag of fs so that setting it is tracked
+func newSettings(fs *flag.FlagSet) *settings {
+	s := &settings{fs: fs, layer: sourceDefault, origins: make(map[string]string)}
+	fs.VisitAll(func(f *flag.Flag) {
+		f.Value = &trackedValue{Value: f.Value, name: f.Name, s: s}
+	})
+	return s
+}
+
+// trackedValue records the layer setting a flag. A list set by a new layer
+// replaces the list of lower layers, and the flags conflicting with it are
+// reset.
+type trackedValue struct {
+	flag.Value
+	name string
+	s    *settings
+}
+
+func (v *trackedValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func (v *trackedValue) Set(value string) error {
+	s := v.s
+	if s.origins[v.name] != s.layer {
+		if list, ok := v.Value.(listFlag); ok {
+			*list.items = nil
+		}
+		for _, pair := range conflicts {
+			for i, name := range pair {
+				peer := pair[1-i]
+				if name != v.name || s.origins[peer] == "" || s.origins[peer] == s.layer {
+					continue
+				}
+				if f := s.fs.Lookup(peer); f != nil {
+					f.Value.(*trackedValue).Value.Set(f.DefValue)
+					delete(s.origins, peer)
+				}
+			}
+		}
+	}
+	if err := v.Value.Set(value); err != nil {
+		return err
+	}
+	s.origins[v.name] = s.layer
+	return nil
+}
+
+// conflict reports whether two flags were set by the same layer
+func (s *settings) conflict(a, b string) bool {
+	return s.origins[a] != "" && s.origins[a] == s.origins[b]
+}
+
+// origin describes where a flag value comes from
+func (s *settings) origin(name string) string {
+	switch origin := s.origins[name]; origin {
+	case "":
+		return sourceDefault
+	case sourceEnv:
+		return sourceEnv + " " + envName(name)
+	default:
+		return origin
+	}
+}
+
+// envName returns the environment variable of a flag
+func envName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyConfig sets the flags from the configuration sections that apply to
+// the command: defaults, then the profile, each followed by its settings for
+// the command
+func (s *settings) applyConfig(cfg *Config, profile, command string) error {
+	apply := func(section Section, layer string) error {
+		if err := s.applySection(section, layer); err != nil {
+			return err
+		}
+		if raw, ok := section[command]; ok {
+			var sub Section
+			if err := json.Unmarshal(raw, &sub); err != nil {
+				return fmt.Errorf("%s.%s: %v", layer, command, err)
+			}
+			return s.applySection(sub, layer+"."+command)
+		}
+		return nil
+	}
+
+	if err := apply(cfg.Defaults, "defaults"); err != nil {
+		return err
+	}
+	if profile == "" {
+		return nil
+	}
+	section, ok := cfg.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("no profile '%s' in %s", profile, cfg.Path)
+	}
+	return apply(section, "profiles."+profile)
+}
+
+// applySection sets the flags of the command found in a section. Settings of
+// other commands are skipped.
+func (s *settings) applySection(section Section, layer string) error {
+	s.layer = sourceConfig + " " + layer
+	for _, name := range sortedKeys(section) {
+		f := s.fs.Lookup(name)
+		if f == nil || findCommand(name) != nil {
+			continue
+		}
+		values, err := settingValues(section[name])
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", layer, name, err)
+		}
+		for _, value := range values {
+			if err := f.Value.Set(value); err != nil {
+				return fmt.Errorf("%s: %s: %v", layer, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyEnv sets the setting flags given by environment variables. Empty
+// variables and the run flags are ignored.
+func (s *settings) applyEnv() error {
+	s.layer = sourceEnv
+	var err error
+	s.fs.VisitAll(func(f *flag.Flag) {
+		if runFlags[f.Name] || err != nil {
+			return
+		}
+		value := os.Getenv(envName(f.Name))
+		if value == "" {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("%s: %v", envName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// settingValues converts a JSON value to flag values: a string, boolean or
+// number is one value, an array is one value per element
+func settingValues(raw json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	scalar := func(v interface{}) (string, bool) {
+		switch v := v.(type) {
+		case string:
+			return v, true
+		case bool:
+			return fmt.Sprint(v), true
+		case json.Number:
+			return v.String(), true
+		}
+		return "", false
+	}
+
+	if items, ok := value.([]interface{}); ok {
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			s, ok := scalar(item)
+			if !ok {
+				return nil, errors.New("array items must be strings, numbers or booleans")
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	s, ok := scalar(value)
+	if !ok {
+		return nil, errors.New("expected a string, number, boolean or array")
+	}
+	return []string{s}, nil
+}
+
+// findConfig returns the configuration file to load: -config, then the
+// environment, then ConfigFile in the current directory or the user
+// configuration directory. It returns "" when there is none.
+func findConfig(path string) string {
+	if path != "" {
+		return path
+	}
+	if path = os.Getenv(ConfigEnv); path != "" {
+		return path
+	}
+	if _, err := os.Stat(ConfigFile); err == nil {
+		return ConfigFile
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		path = filepath.Join(dir, "hashtocrack", ConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadConfig reads and checks a configuration file. Unknown keys are errors,
+// so typos are not silently ignored.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Path: path}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	known := knownFlags()
+	check := func(section Section, layer string) error {
+		for name, raw := range section {
+			if c := findCommand(name); c != nil && c.dispatch == nil {
+				if name != c.name {
+					return fmt.Errorf("%s: %s: use '%s' for the settings of the command", path, layer, c.name)
+				}
+				var sub Section
+				if err := json.Unmarshal(raw, &sub); err != nil {
+					return fmt.Errorf("%s: %s.%s: %v", path, layer, name, err)
+				}
+				for subName := range sub {
+					if err := checkSetting(subName, known); err != nil {
+						return fmt.Errorf("%s: %s.%s: %v", path, layer, name, err)
+					}
+				}
+				continue
+			}
+			if err := checkSetting(name, known); err != nil {
+				return fmt.Errorf("%s: %s: %v", path, layer, err)
+			}
+		}
+		return nil
+	}
+
+	if err := check(cfg.Defaults, "defaults"); err != nil {
+		return nil, err
+	}
+	for name, section := range cfg.Profiles {
+		if err := check(section, "profiles."+name); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// checkSetting rejects keys that are not flags of any command
+func checkSetting(name string, known map[string]bool) error {
+	switch {
+	case name == "config" || name == "profile":
+		return fmt.Errorf("'%s' cannot be set in a configuration file", name)
+	case !known[name]:
+		return fmt.Errorf("unknown setting '%s'", name)
+	}
+	return nil
+}
+
+// knownFlags returns the names of the flags of every command
+func knownFlags() map[string]bool {
+	known := make(map[string]bool)
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	allFlags(fs, newOptions())
+	fs.VisitAll(func(f *flag.Flag) { known[f.Name] = true })
+	return known
+}
+
+// allFlags registers the flags of every command, for 'config show' without
+// a command
+func allFlags(fs *flag.FlagSet, opts *Options) {
+	for _, c := range commands {
+		if c.dispatch != nil {
+			continue
+		}
+		c.flagSet(opts).VisitAll(func(f *flag.Flag) {
+			if fs.Lookup(f.Name) == nil {
+				fs.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+	}
+}
+
+// resolve builds the options of a command from the defaults, the
+// configuration file, the environment and the command line, in increasing
+// precedence, and returns the positional arguments
+func (c *command) resolve(args []string) (*Options, *settings, []string) {
+	// -config and -profile choose the configuration, so they are found
+	// before the layers below the command line are applied
+	pre := newOptions()
+	c.parse(c.flagSet(pre), args)
+	if pre.Profile == "" {
+		pre.Profile = os.Getenv(ProfileEnv)
+	}
+
+	opts := newOptions()
+	s := newSettings(c.flagSet(opts))
+
+	if path := findConfig(pre.Config); path != "" {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
+		if err := s.applyConfig(cfg, pre.Profile, c.name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error applying configuration: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Config = path
+	} else if pre.Profile != "" {
+		fmt.Fprintf(os.Stderr, "Error: profile '%s' requires a configuration file (-config, %s or ./%s)\n", pre.Profile, ConfigEnv, ConfigFile)
+		os.Exit(1)
+	}
+	opts.Profile = pre.Profile
+
+	if err := s.applyEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error in environment: %v\n", err)
+		os.Exit(1)
+	}
+
+	s.layer = sourceFlag
+	positional := c.parse(s.fs, args)
+	return opts, s, positional
+}
+
+// runConfig executes the config command
+func runConfig(args []string, version string) {
+	self := findCommand("config")
+	if len(args) == 0 {
+		usageError(self, "missing action (show)")
+	}
+	switch args[0] {
+	case "-h", "-help", "--help":
+		PrintCommandHelp(self.name)
+		os.Exit(0)
+	case "show":
+	default:
+		usageError(self, "unknown action '%s' (show)", args[0])
+	}
+
+	// Settings are shown for one command, or for every flag of every command
+	target := &command{name: self.name, synopsis: self.synopsis, summary: self.summary, details: self.details, flags: []flagGroup{allFlags}}
+	args = args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target = findCommand(args[0])
+		if target == nil || target.dispatch != nil || len(target.flags) == 0 {
+			usageError(self, "no settings for command '%s'", args[0])
+		}
+		args = args[1:]
+	}
+
+	opts, s, positional := target.resolve(args)
+	if len(positional) > len(target.args) {
+		usageError(target, "unexpected argument '%s'", positional[len(target.args)])
+	}
+
+	source := "none"
+	if opts.Config != "" {
+		source = opts.Config
+		if opts.Profile != "" {
+			source += " (profile " + opts.Profile + ")"
+		}
+	}
+	fmt.Printf("Configuration: %s\n", source)
+	if target.name != self.name {
+		fmt.Printf("Command: %s\n", target.name)
+	}
+	fmt.Println()
+
+	var names []string
+	width := 0
+	s.fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+		if len(f.Name) > width {
+			width = len(f.Name)
+		}
+	})
+	for _, name := range names {
+		value := s.fs.Lookup(name).Value.String()
+		if name == "secret" && value != "" {
+			value = "<redacted>"
+		}
+		if value == "" {
+			value = `""`
+		}
+		fmt.Printf("  %-*s  %-24s %s\n", width, name, value, s.origin(name))
+	}
+}
+
+func configFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.Config, "config", "", "Configuration `file` (default: "+ConfigEnv+", then ./"+ConfigFile+")")
+	fs.StringVar(&opts.Profile, "profile", "", "Configuration profile `name` (default: "+ProfileEnv+")")
+}
+
+// sortedKeys returns the keys of a section in order
+func sortedKeys(section Section) []string {
+	keys := make([]string, 0, len(section))
+	for key := range section {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
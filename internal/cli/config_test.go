@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const layersConfig = `{
+  "defaults": {
+    "min-share": 2,
+    "pair-distance": 3,
+    "pair-patterns": ["adm_{user}"],
+    "o": "defaults.txt",
+    "analyze": {"min-share": 3},
+    "match": {"min-share": 9}
+  },
+  "profiles": {
+    "client": {
+      "min-share": 4,
+      "redact": "mask",
+      "pair-patterns": ["{user}-a", "{user}-b"],
+      "analyze": {"passpol": true, "outfile": "profile.txt"}
+    }
+  }
+}`
+
+func TestResolveLayers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFile)
+	if err := os.WriteFile(path, []byte(layersConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ProfileEnv, "")
+	t.Setenv(EnvPrefix+"MIN_SHARE", "5")
+	t.Setenv(EnvPrefix+"PAIR_DISTANCE", "4")
+	// -outfile is a run flag: the environment cannot set it
+	t.Setenv(EnvPrefix+"OUTFILE", "env.txt")
+
+	opts, s, positional := findCommand("analyze").resolve([]string{"-config", path, "-profile", "client", "matched.txt", "-pair-distance", "1"})
+
+	if !reflect.DeepEqual(positional, []string{"matched.txt"}) {
+		t.Errorf("positional arguments %v", positional)
+	}
+	if opts.Config != path || opts.Profile != "client" {
+		t.Errorf("config %q, profile %q", opts.Config, opts.Profile)
+	}
+	if opts.MinShare != 5 || opts.PairDistance != 1 || opts.Redact != "mask" || !opts.PassPol {
+		t.Errorf("min-share %d, pair-distance %d, redact %q, passpol %v", opts.MinShare, opts.PairDistance, opts.Redact, opts.PassPol)
+	}
+	// A list set by a higher layer replaces the list of lower layers
+	if want := []string{"{user}-a", "{user}-b"}; !reflect.DeepEqual(opts.PairPatterns, want) {
+		t.Errorf("pair-patterns %v, want %v", opts.PairPatterns, want)
+	}
+	// -outfile from the profile replaces the conflicting -o of the defaults
+	if opts.OutFile != "profile.txt" {
+		t.Errorf("outfile %q", opts.OutFile)
+	}
+
+	origins := map[string]string{
+		"min-share":     "env " + EnvPrefix + "MIN_SHARE",
+		"pair-distance": "flag",
+		"pair-patterns": "config profiles.client",
+		"redact":        "config profiles.client",
+		"passpol":       "config profiles.client.analyze",
+		"outfile":       "config profiles.client.analyze",
+		"o":             "default",
+		"disabled":      "default",
+	}
+	for name, want := range origins {
+		if got := s.origin(name); got != want {
+			t.Errorf("origin(%s) = %q, want %q", name, got, want)
+		}
+	}
+	if s.conflict("o", "outfile") {
+		t.Error("-o and -outfile reported as set together")
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	cfg := &Config{Path: ConfigFile, Defaults: Section{"min-share": []byte(`{"k": 1}`)}}
+	s := newSettings(findCommand("analyze").flagSet(newOptions()))
+	if err := s.applyConfig(cfg, "", "analyze"); err == nil || !strings.Contains(err.Error(), "min-share") {
+		t.Errorf("object value: error %v", err)
+	}
+
+	cfg.Defaults = Section{}
+	if err := s.applyConfig(cfg, "client", "analyze"); err == nil || !strings.Contains(err.Error(), "no profile 'client'") {
+		t.Errorf("unknown profile: error %v", err)
+	}
+}
+
+func TestSettingValues(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`"mask"`, []string{"mask"}},
+		{`true`, []string{"true"}},
+		{`3`, []string{"3"}},
+		{`["a.txt", 2, false]`, []string{"a.txt", "2", "false"}},
+		{`[]`, []string{}},
+		{`{"a": 1}`, nil},
+		{`[["a"]]`, nil},
+		{`null`, nil},
+	}
+	for _, tt := range tests {
+		got, err := settingValues([]byte(tt.raw))
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("settingValues(%s) accepted", tt.raw)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("settingValues(%s) = %q, %v, want %q", tt.raw, got, err, tt.want)
+		}
+	}
+}
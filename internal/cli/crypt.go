@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fisher0x/hashtocrack/internal/seal"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// PassphraseEnv names the environment variable holding the encryption passphrase
+const PassphraseEnv = "HASHTOCRACK_PASSPHRASE"
+
+// loadPassphrase returns the passphrase read from -passphrase-file, or from
+// the environment
+func loadPassphrase(opts *Options) ([]byte, error) {
+	if opts.PassphraseFile == "" {
+		return []byte(os.Getenv(PassphraseEnv)), nil
+	}
+	data, err := os.ReadFile(opts.PassphraseFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading passphrase file: %v", err)
+	}
+	return []byte(strings.TrimRight(string(data), "\r\n")), nil
+}
+
+// configureKeys loads the passphrase, recipients and identities used to
+// encrypt outputs and decrypt inputs
+func configureKeys(opts *Options) {
+	passphrase, err := loadPassphrase(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	keys := seal.Keys{Passphrase: passphrase, Encrypt: opts.Encrypt}
+
+	for _, value := range opts.Recipients {
+		recipient, err := seal.LoadRecipient(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -recipient: %v\n", err)
+			os.Exit(1)
+		}
+		keys.Recipients = append(keys.Recipients, recipient)
+	}
+	for _, filename := range opts.Identities {
+		identities, err := seal.LoadIdentities(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -identity: %v\n", err)
+			os.Exit(1)
+		}
+		keys.Identities = append(keys.Identities, identities...)
+	}
+
+	if opts.Encrypt && !keys.Sealing() {
+		fmt.Fprintf(os.Stderr, "Error: -encrypt requires a passphrase (-passphrase-file or %s) or -recipient\n", PassphraseEnv)
+		os.Exit(1)
+	}
+	seal.Configure(keys)
+}
+
+// RunKeygen executes the keygen command: it writes a new X25519 identity and
+// prints its public key
+func RunKeygen(opts *Options) {
+	if opts.NTDSFile == "" {
+		fmt.Fprintf(os.Stderr, "Usage: HashToCrack keygen <keyfile>\n")
+		os.Exit(1)
+	}
+
+	identity, err := seal.GenerateIdentity()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating key: %v\n", err)
+		os.Exit(1)
+	}
+	publicKey := seal.FormatPublicKey(identity.PublicKey())
+
+	if err := utils.EnsureDir(opts.NTDSFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating key directory: %v\n", err)
+		os.Exit(1)
+	}
+	file, err := os.OpenFile(opts.NTDSFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating key file: %v\n", err)
+		os.Exit(1)
+	}
+	_, err = fmt.Fprintf(file, "# created: %s\n# public key: %s\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), publicKey, seal.FormatPrivateKey(identity))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing key file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(publicKey)
+	fmt.Fprintf(os.Stderr, "[+] Private key written to: %s\n", opts.NTDSFile)
+}
+
+// RunEncrypt executes the encrypt command
+func RunEncrypt(opts *Options, version string) {
+	if opts.NTDSFile == "" || opts.OutFile == "" {
+		fmt.Fprintf(os.Stderr, "Usage: HashToCrack encrypt <file> (-recipient <key> | -passphrase-file <file>) -o <outfile>\n")
+		os.Exit(1)
+	}
+	opts.Encrypt = true
+	configureKeys(opts)
+
+	m := startManifest(opts, "encrypt", version)
+	recordInputs(m, "input", opts.NTDSFile)
+
+	output, err := seal.Create(opts.OutFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+		os.Exit(1)
+	}
+	if err := output.Finish(copyInput(output, opts.NTDSFile)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encrypting file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "[+] Encrypted file written to: %s\n", opts.OutFile)
+	finishManifest(m, opts)
+}
+
+// RunDecrypt executes the decrypt command
+func RunDecrypt(opts *Options, version string) {
+	if opts.NTDSFile == "" {
+		fmt.Fprintf(os.Stderr, "Usage: HashToCrack decrypt <file> (-identity <keyfile> | -passphrase-file <file>) [-o <outfile>]\n")
+		os.Exit(1)
+	}
+	opts.Encrypt = false
+	opts.Recipients = nil
+	configureKeys(opts)
+
+	m := startManifest(opts, "decrypt", version)
+	recordInputs(m, "input", opts.NTDSFile)
+	defer finishManifest(m, opts)
+
+	if opts.OutFile == "" {
+		if err := copyInput(os.Stdout, opts.NTDSFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error decrypting file: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	output, err := seal.CreatePlain(opts.OutFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+		os.Exit(1)
+	}
+	if err := output.Finish(copyInput(output, opts.NTDSFile)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decrypting file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "[+] Decrypted file written to: %s\n", opts.OutFile)
+}
+
+// copyInput copies an input file, decrypted if needed, to w
+func copyInput(w io.Writer, filename string) error {
+	file, err := seal.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
@@ -1,18 +1,27 @@
 package cli
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+)
 
 // PrintUsage displays a brief usage message
 func PrintUsage() {
 	fmt.Println(`HashToCrack - NTDS Hash Analyzer & Password Statistics Tool
 
 Usage:
-  HashToCrack <ntdsfile> [-disabled] [-machines] [-o <outfile>]
-  HashToCrack <ntdsfile> <crackfile> [-disabled] [-machines] [-o <outfile>]
-  HashToCrack <analyticsfile> [-disabled] [-machines] [-passpol] [-report] [-o <outfile>]
-  HashToCrack help
+  HashToCrack extract <ntdsfile> [-disabled] [-machines] [-o <outfile>]
+  HashToCrack match <ntdsfile> <potfile> [-disabled] [-machines] [-o <outfile>]
+  HashToCrack analyze <matchedfile> [-disabled] [-machines] [-passpol] [-pairs] [-report] [-o <outfile>]
+  HashToCrack anonymize <matchedfile> -secret-file <file> [-passwords mask|hash] [-o <outfile>]
+  HashToCrack keygen <keyfile>
+  HashToCrack encrypt <file> (-recipient <key> | -passphrase-file <file>) -o <outfile>
+  HashToCrack decrypt <file> (-identity <keyfile> | -passphrase-file <file>) [-o <outfile>]
+  HashToCrack verify-manifest <manifest|report.json|report.html>
+  HashToCrack help [command]
 
-Run 'HashToCrack help' for more information.`)
+Run 'HashToCrack help' for more information, or 'HashToCrack <command> -h'
+for the options of a command.`)
 }
 
 // PrintHelp displays the full help message
@@ -26,60 +35,344 @@ DESCRIPTION:
   cracked hashes with their owners and generate comprehensive statistics.
 
 USAGE:
-  HashToCrack <ntdsfile> [options]
-  HashToCrack <ntdsfile> <crackfile> [options]
-  HashToCrack <analyticsfile> [options]
-  HashToCrack help
+  HashToCrack <command> <arguments> [options]
+  HashToCrack <command> -h
+  HashToCrack help [command]
+
+COMMANDS:
+`, version)
+	printCommands(os.Stdout)
+	fmt.Printf(`
+  Each command accepts only its own flags: unknown flags, missing or extra
+  arguments and conflicting flags are errors. Flags may be placed before or
+  after the arguments.
+
+  The positional form 'HashToCrack <file> [<potfile>] [options]', which
+  guesses the mode from the arguments and the file content, still works but
+  is deprecated.
 
 MODES:
 
   1. EXTRACT MODE - Extract hashes from NTDS file
-     HashToCrack <ntdsfile> [-disabled] [-machines] [-o <outfile>]
+     HashToCrack extract <ntdsfile> [-disabled] [-machines] [-o <outfile>]
      
      Extracts NT hashes from the NTDS file. By default, only enabled 
      user accounts are included (machine accounts excluded).
      
      Examples:
-       HashToCrack NTDS.dit                    # Extract enabled user hashes
-       HashToCrack NTDS.dit -disabled          # Include disabled accounts
-       HashToCrack NTDS.dit -machines          # Include machine accounts
-       HashToCrack NTDS.dit -o hashes.txt      # Save to file
+       HashToCrack extract NTDS.dit                    # Extract enabled user hashes
+       HashToCrack extract NTDS.dit -disabled          # Include disabled accounts
+       HashToCrack extract NTDS.dit -machines          # Include machine accounts
+       HashToCrack extract NTDS.dit -o hashes.txt      # Save to file
+       HashToCrack extract NTDS.dit -format jsonl      # One JSON object per account
+
+     Hash list formats (-format), all following -disabled and -machines:
+       text      One NT hash per account (default)
+       unique    Every NT hash once, the most shared first
+       username  user:hash lines for hashcat --username
+       john      user:$NT$hash lines for John the Ripper (--format=NT)
+       pwdump    Full user:rid:lm:nt::: lines
+       lmnt      LM:NT pairs for impacket's -hashes
+
+     -exclude-pot leaves out the accounts whose NT hash is already cracked
+     in one or more potfiles, and prints the unique hashes remaining and
+     the accounts they cover:
+       HashToCrack extract NTDS.dit -exclude-pot hashcat.potfile,old.pot -format unique
 
   2. MATCH MODE - Match NTDS with cracked passwords
-     HashToCrack <ntdsfile> <crackfile> [-disabled] [-machines] [-o <outfile>]
+     HashToCrack match <ntdsfile> <potfile> [-disabled] [-machines] [-o <outfile>]
      
      Matches hashes from NTDS file with a hashcat potfile and displays
      usernames with their cracked passwords.
      
-     Output format: username:hash:password:status
+     Output format: versioned tab-separated matched file
+       #HashToCrack matched v1
+       username rid nt_hash lm_status status cracked empty_password password
      
      Examples:
-       HashToCrack NTDS.dit potfile.txt
-       HashToCrack NTDS.dit potfile.txt -disabled -machines -o matched.txt
+       HashToCrack match NTDS.dit potfile.txt
+       HashToCrack match NTDS.dit potfile.txt -disabled -machines -o matched.txt
+       HashToCrack match NTDS.dit potfile.txt -bh-cypher owned.cypher -bh-domains CORP=corp.local
+
+     -watch follows the potfile while hashcat runs: the matched file is
+     written once, then a record is appended for every newly cracked
+     account (a later record of an account replaces the earlier one). A
+     live status line shows cracked/total, newly cracked privileged
+     accounts (with -groups) and the crack rate. Truncated or rotated
+     potfiles are read again from the start. Stop with Ctrl-C.
+       HashToCrack match NTDS.dit hashcat.potfile -watch -groups groups.json -o matched.txt
 
   3. ANALYTICS MODE - Generate password statistics
-     HashToCrack <analyticsfile> [-disabled] [-machines] [-passpol] [-report] [-o <outfile>]
+     HashToCrack analyze <matchedfile> [-disabled] [-machines] [-passpol] [-pairs] [-report] [-o <outfile>]
      
      Analyzes a matched file (output from match mode) and generates 
-     comprehensive password statistics.
+     comprehensive password statistics. Legacy username:hash:password:status
+     matched files are also accepted.
      
      Statistics include:
        - Total and cracked password counts
        - Password length distribution
        - Top 10 most common passwords
        - Password complexity compliance (DOMAIN_PASSWORD_COMPLEX)
+       - Admin/user paired accounts reusing credentials (-pairs)
+       - Cracked privileged accounts and privileged vs. non-privileged
+         crack/reuse rates (-groups)
      
      Examples:
-       HashToCrack matched.txt -passpol
-       HashToCrack matched.txt -disabled -machines -passpol
-       HashToCrack matched.txt -passpol -report      # Redact passwords in output
+       HashToCrack analyze matched.txt -passpol
+       HashToCrack analyze matched.txt -disabled -machines -passpol
+       HashToCrack analyze matched.txt -passpol -report      # Redact passwords in output
+       HashToCrack analyze matched.txt -pairs -pair-patterns "{user}_adm,adm-{user}"
+       HashToCrack analyze matched.txt -groups groups.json,users.json -report
+       HashToCrack analyze matched.txt -passpol -format json -o report.json
+       HashToCrack analyze matched.txt -passpol -report -format html -o report.html
+       HashToCrack analyze matched.txt -passpol -report -format markdown -o findings.md
+       HashToCrack analyze matched.txt -passpol -report -template client.tmpl -o report.txt
+       HashToCrack analyze matched.txt -passpol -pairs -report -format xlsx -o report.xlsx
+       HashToCrack analyze matched.txt -passpol -report -charts charts/
+
+  4. ANONYMIZE - Pseudonymize a matched file for sharing
+     HashToCrack anonymize <matchedfile> -secret-file <file> [-passwords mask|hash] [-o <outfile>]
+
+     Replaces usernames and domains with stable HMAC pseudonyms keyed by a
+     per-engagement secret, NT hashes with keyed hashes, and passwords with
+     tokens. Admin naming patterns are kept (jdoe_adm -> user-..._adm), and
+     RIDs are dropped. With the default mask tokens, analytics gives the same
+     statistics on the anonymized file.
+
+     Examples:
+       HashToCrack anonymize matched.txt -secret-file engagement.key -o shared.txt
+       HashToCrack anonymize matched.txt -secret-file engagement.key -passwords hash
+
+  5. ENCRYPTION - Encrypted-at-rest inputs and outputs
+     HashToCrack keygen <keyfile>
+     HashToCrack encrypt <file> (-recipient <key> | -passphrase-file <file>) -o <outfile>
+     HashToCrack decrypt <file> (-identity <keyfile> | -passphrase-file <file>) [-o <outfile>]
+
+     Output files are always written owner-only (0600) through a temporary
+     file renamed into place. With -encrypt (passphrase) or -recipient
+     (X25519 public key), every output file is also encrypted with
+     AES-256-GCM. Every mode decrypts encrypted inputs given -identity or
+     the passphrase. Passphrases use PBKDF2-HMAC-SHA256 (scrypt is not in
+     the Go standard library).
+
+     Examples:
+       HashToCrack keygen engagement.key        # Prints the htcpub1: public key
+       HashToCrack match NTDS.dit potfile.txt -recipient engagement.key -o matched.enc
+       HashToCrack analyze matched.enc -passpol -identity engagement.key
+       HashToCrack match NTDS.dit potfile.txt -encrypt -passphrase-file pass.txt -o matched.enc
+       HashToCrack decrypt matched.enc -identity engagement.key -o matched.txt
+
+  6. EVIDENCE MANIFEST - Chain-of-custody records
+     HashToCrack verify-manifest <manifest|report.json|report.html>
+
+     Every run writing to a file (-o) also writes <outfile>.manifest.json:
+     tool version, all options, UTC timestamps, host, operator, account
+     counts, and the SHA-256 of every input and output file. JSON and HTML
+     analytics reports embed it too (inputs only). verify-manifest
+     re-hashes the recorded files and exits with status 1 on any change.
+
+     Examples:
+       HashToCrack match NTDS.dit potfile.txt -operator "J. Smith" -o matched.txt
+       HashToCrack verify-manifest matched.txt.manifest.json
+       HashToCrack verify-manifest report.html
+
+  7. CONFIGURATION - Engagement settings and profiles
+     HashToCrack config show [<command>] [options]
+
+     Settings are merged with the precedence: built-in defaults, then the
+     configuration file, then HASHTOCRACK_<FLAG> environment variables
+     (e.g. HASHTOCRACK_FORMAT, HASHTOCRACK_PRIV_GROUPS), then flags. The
+     output files (-o, -manifest, -bh-cypher, ...) and -check and -watch
+     have no environment variable. The file is -config, HASHTOCRACK_CONFIG,
+     ./hashtocrack.json or hashtocrack/hashtocrack.json in the user
+     configuration directory.
+
+     The JSON file maps flag names to values in "defaults" and in named
+     "profiles" (-profile or HASHTOCRACK_PROFILE). A key naming a command
+     holds settings for that command only. Unknown settings are errors.
+     config show prints every effective setting and where it comes from.
+
+       {
+         "defaults": {"disabled": true, "analyze": {"format": "html"}},
+         "profiles": {
+           "client": {"report": true, "redact": "mask",
+                      "priv-groups": ["Tier0 Admins"]}
+         }
+       }
+
+     Examples:
+       HashToCrack analyze matched.txt -profile client -o report.html
+       HashToCrack config show analyze -profile client
+
+  8. WORKSPACE - Engagement directory
+     HashToCrack init <dir>
+     HashToCrack workspace status | add <role> <file>...
+
+     init creates a workspace with ntds/, potfiles/, hashes/, matched/,
+     reports/, anonymized/ and wordlists/ directories and an index of its
+     files and runs (hashtocrack-workspace.json). Inside it (or with
+     -workspace), extract, match, crack, wordlist, predict, potmerge,
+     analyze and anonymize take the latest file of each missing argument's
+     role, write their output to the role's directory unless -o is given,
+     and record the run with its inputs, output and manifest. Roles: ntds, potfile, hashes,
+     matched, report, anonymized, wordlist.
+
+     Examples:
+       HashToCrack init acme && cd acme
+       HashToCrack workspace add ntds /cases/acme/ntds.txt
+       HashToCrack workspace add potfile ~/.local/share/hashcat/hashcat.potfile
+       HashToCrack match
+       HashToCrack analyze -passpol -format html
+       HashToCrack workspace status
+
+  9. CRACK - Run a hashcat attack plan
+     HashToCrack crack <ntdsfile> <potfile> -plan plan.json -o matched.txt
+
+     Extracts the NT hashes as in extract mode, then runs the stages of a
+     JSON attack plan in order with hashcat (-m 1000) writing to the
+     potfile, and writes the match output again after every stage. Stage
+     types: wordlist (wordlists), rules (wordlists and rules) and mask
+     (mask, optional increment). A stage exiting with an error or an
+     interrupt stops the plan; the summary lists the exit status, time
+     and new cracks of each stage.
+
+     Plan example:
+       {"args": ["-O", "-w", "3"],
+        "stages": [{"name": "rockyou", "type": "wordlist", "wordlists": ["rockyou.txt"]},
+                   {"type": "rules", "wordlists": ["rockyou.txt"], "rules": ["best64.rule"]},
+                   {"type": "mask", "mask": "?u?l?l?l?l?d?d?s", "runtime": 3600}]}
+
+     Examples:
+       HashToCrack crack NTDS.dit hashcat.potfile -plan plan.json -o matched.txt
+       HashToCrack crack NTDS.dit hashcat.potfile -plan plan.json -hashcat /opt/hashcat/hashcat.bin -log stages.json -o matched.txt
+
+  10. WORDLIST - Engagement-specific candidates
+     HashToCrack wordlist <ntdsfile> [-keywords words.txt] [-o wordlist.txt]
+
+     Builds candidates from the account names and their parts (john.doe:
+     john, doe), the NetBIOS domain names, keyword files, the seasons and
+     months, and the years around today, with common suffixes (1, 123, !,
+     2026!, @2026, ...), case variants and leet substitutions. Candidates
+     are deduplicated and ordered by estimated likelihood: keywords and the
+     domain name first, then the current season and month, then name parts
+     shared by many accounts. -check hashes every candidate (NT hash) and
+     appends the matches to a potfile for match.
+
+     Examples:
+       HashToCrack wordlist NTDS.dit -keywords acme.txt -min-length 8 -o acme.dict
+       HashToCrack wordlist NTDS.dit -limit 100000 | hashcat -m 1000 hashes.txt
+       HashToCrack wordlist NTDS.dit -check hashcat.potfile -o acme.dict
+
+  11. PREDICT - Next and previous passwords
+     HashToCrack predict <matchedfile> [-per-account] [-o predictions.txt]
+
+     Predicts the passwords cracked accounts used before and will use
+     after: a season, month (January or Jan) or quarter (Q3) moves to the
+     next or previous one, carrying into the year when it wraps
+     (Winter2024 -> Spring2025); a year moves by itself (Summer2025); and
+     otherwise a trailing number is incremented (Welcome09 -> Welcome10) or
+     appended (Changeme -> Changeme1). Without -per-account the output is a
+     wordlist ordered by the number of accounts predicting each password;
+     with it, one user:password line per prediction, closest first.
+
+     Examples:
+       HashToCrack predict matched.txt -o next.dict
+       HashToCrack predict matched.txt -direction next -steps 2 -per-account -o spray.txt
+
+  12. POTMERGE - Merge potfiles
+     HashToCrack potmerge <potfile>... [-type nt,lm,other] [-o merged.pot]
+
+     Merges potfiles in order: NT and LM hashes are lowercased, $HEX[]
+     passwords decoded and re-encoded the way hashcat writes them, and
+     duplicates dropped. When a hash has different passwords, the one
+     whose NT hash (computed with a built-in MD4) matches the hash is
+     kept; otherwise the first one is kept and the conflict reported.
+     Other hash types (NetNTLM, Kerberos, ...) are deduplicated by line.
+     A table shows what each potfile contributed.
+
+     Examples:
+       HashToCrack potmerge hashcat.potfile old.pot team.pot -o merged.pot
+       HashToCrack potmerge *.pot -type nt -o nt.pot
 
 OPTIONS:
   -disabled       Include disabled accounts in the analysis
   -machines       Include machine accounts (accounts ending with $)
   -passpol        Show password policy compliance statistics
-  -report         Redact passwords in output (show first 3 chars only)
+  -report         Redact passwords in every output (default: first 3 chars)
+  -redact         Redaction strategy, implies -report: full, mask (Ulllldd!),
+                  first:N, last:N, ends:N, length, or hash (keyed, -secret)
+  -redact-min     Fully mask passwords shorter than N characters (default: 8)
+  -min-share      (analytics) Only disclose passwords used by at least k
+                  accounts; others are aggregated into an "Other" line
+  -pairs          Report admin/user account pairs with reused credentials
+  -pair-patterns  Comma-separated admin naming patterns using {user}
+                  (default: {user}_adm, adm-{user}, {user}.admin, ...)
+  -pair-distance  Max edit distance for similar pair passwords (default: 2)
+  -groups         Comma-separated group membership files: ldapdomaindump
+                  domain_users.json, SharpHound groups.json/users.json, or
+                  "group:member" text (every listed group is privileged)
+  -priv-groups    Comma-separated extra group names to treat as privileged
+  -watch          (match) Follow the potfile and append newly cracked accounts
+                  to the output until interrupted (text or jsonl)
+  -watch-interval (match) Potfile polling interval for -watch (default: 2s)
+  -exclude-pot    (extract) Comma-separated potfiles whose cracked hashes are
+                  left out (repeatable)
+  -type           (potmerge) Comma-separated hash types to keep: nt, lm, other
+                  (default: all)
+  -plan           (crack) JSON attack plan file
+  -hashcat        (crack) hashcat executable (default: hashcat)
+  -hashes         (crack) Keep the extracted hash list in a file
+  -log            (crack) Write the status, time and cracks of every stage
+                  to a JSON file
+  -keywords       (wordlist) Comma-separated keyword files, one word per line
+  -years          (wordlist) Number of past years combined with the words
+                  (default: 2; next year is always included)
+  -min-length     (wordlist) Drop candidates shorter than N characters
+  -limit          (wordlist) Keep the N most likely candidates
+  -check          (wordlist) Check the candidates against the NT hashes and
+                  append cracks to a potfile
+  -direction      (predict) Predicted passwords: next, previous or both
+                  (default: both)
+  -steps          (predict) Number of rotations in each direction (default: 1)
+  -per-account    (predict) Write user:password predictions for every account
+  -bh-cypher      (match) Write a Cypher script marking cracked users as owned
+  -bh-json        (match) Write a BloodHound CE users.json ingest file
+  -bh-domains     NetBIOS to FQDN mapping for BloodHound names (CORP=corp.local,...)
+  -bh-sids        NetBIOS to domain SID mapping for the ingest file (CORP=S-1-5-21-...)
+  -format         Output format: text (default), json or jsonl; extract
+                  also unique, username, john, pwdump and lmnt;
+                  analytics also supports html, markdown (md) and
+                  asciidoc (adoc); match and analytics also xlsx
+                  (requires -o; -report redacts match workbooks too)
+  -charts         (analytics) Write SVG and PNG charts of the report
+                  sections into a directory
+  -template       (analytics) Render the report with a Go template file;
+                  .html/.htm templates (or -format html) use html/template
+  -secret         (anonymize, -redact hash) Per-engagement secret
+  -secret-file    (anonymize) Read the secret from a file (keeps it out of
+                  shell history)
+  -passwords      (anonymize) Password tokens: mask (default; same length and
+                  character classes) or hash (keyed hash, equality only)
+  -encrypt        Encrypt every output file with the passphrase
+  -passphrase-file
+                  Read the passphrase from a file (default: the
+                  HASHTOCRACK_PASSPHRASE environment variable); also
+                  decrypts passphrase-encrypted inputs
+  -recipient      Encrypt every output file to a htcpub1: public key or key
+                  file (comma-separated or repeated)
+  -identity       Private key file decrypting encrypted inputs (repeatable)
+  -manifest       Evidence manifest path (default: <outfile>.manifest.json
+                  when -o is given; required for a manifest of stdout runs)
+  -no-manifest    Do not write an evidence manifest
+  -operator       Operator recorded in the manifest (default:
+                  HASHTOCRACK_OPERATOR, then the login name)
   -o, -outfile    Write output to specified file instead of stdout
+  -config         Configuration file (default: HASHTOCRACK_CONFIG, then
+                  ./hashtocrack.json)
+  -profile        Configuration profile (default: HASHTOCRACK_PROFILE)
+  -workspace      Workspace directory (default: the workspace containing the
+                  current directory)
 
 NTDS FILE FORMAT:
   Expected format (secretsdump output):
@@ -88,5 +381,6 @@ NTDS FILE FORMAT:
 CRACKFILE FORMAT:
   Standard hashcat potfile format:
   hash:password
-`, version)
+  Passwords written as $HEX[...] are decoded.
+`)
 }
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fisher0x/hashtocrack/internal/manifest"
+)
+
+// OperatorEnv names the environment variable holding the default operator
+const OperatorEnv = "HASHTOCRACK_OPERATOR"
+
+// manifestPath returns where the sidecar manifest is written: -manifest, or
+// next to the output file. Runs writing to stdout only get one with -manifest.
+func manifestPath(opts *Options) string {
+	switch {
+	case opts.NoManifest:
+		return ""
+	case opts.Manifest != "":
+		return opts.Manifest
+	case opts.OutFile != "":
+		return opts.OutFile + ".manifest.json"
+	}
+	return ""
+}
+
+// startManifest begins the evidence manifest of a run, or returns nil when
+// no manifest is written
+func startManifest(opts *Options, command, version string) *manifest.Manifest {
+	if manifestPath(opts) == "" {
+		return nil
+	}
+
+	// The options are recorded as given, except the secret itself
+	recorded := *opts
+	if recorded.Secret != "" {
+		recorded.Secret = "<redacted>"
+	}
+	operator := opts.Operator
+	if operator == "" {
+		operator = os.Getenv(OperatorEnv)
+	}
+
+	m := manifest.New(command, version, recorded, operator)
+	if opts.Config != "" {
+		recordInputs(m, "config", opts.Config)
+	}
+	return m
+}
+
+// recordInputs adds input files to the manifest, exiting on failure
+func recordInputs(m *manifest.Manifest, role string, filenames ...string) {
+	for _, filename := range filenames {
+		if err := m.AddInput(role, filename); err != nil {
+			fmt.Fprintf(os.Stderr, "Error hashing input file for the manifest: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// finishManifest records the main output file and writes the sidecar manifest
+func finishManifest(m *manifest.Manifest, opts *Options) {
+	if m == nil {
+		return
+	}
+	if opts.OutFile != "" {
+		if err := m.AddOutput("output", opts.OutFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error hashing output file for the manifest: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	m.Finish()
+
+	path := manifestPath(opts)
+	if err := m.Write(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing manifest: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "[+] Evidence manifest written to: %s\n", path)
+}
+
+// RunVerifyManifest executes the verify-manifest command: it re-hashes the
+// files recorded in a manifest, or in the manifest embedded in a report
+func RunVerifyManifest(opts *Options) {
+	if opts.NTDSFile == "" {
+		fmt.Fprintf(os.Stderr, "Usage: HashToCrack verify-manifest <manifest|report.json|report.html> [-identity <keyfile>]\n")
+		os.Exit(1)
+	}
+	configureKeys(opts)
+
+	m, err := manifest.Load(opts.NTDSFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading manifest: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Manifest: %s v%s %s by %s on %s at %s\n", m.Tool, m.Version, m.Command, m.Operator, m.Hostname,
+		m.StartedAt.Format("2006-01-02 15:04:05 UTC"))
+
+	failed := 0
+	for _, check := range m.Verify(filepath.Dir(opts.NTDSFile)) {
+		status := "OK"
+		switch {
+		case check.Err != nil:
+			status = "ERROR"
+		case check.Status == manifest.StatusModified:
+			status = "MODIFIED"
+		case check.Status == manifest.StatusMissing:
+			status = "MISSING"
+		}
+		if status != "OK" {
+			failed++
+		}
+
+		fmt.Printf("  [%s] %s %s\n", status, check.Role, check.Path)
+		if check.FoundAt != "" {
+			fmt.Printf("      found at %s\n", check.FoundAt)
+		}
+		if check.Err != nil {
+			fmt.Printf("      %v\n", check.Err)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("[!] %d file(s) failed verification\n", failed)
+		os.Exit(1)
+	}
+	fmt.Printf("[+] All %d file(s) match the manifest\n", len(m.Inputs)+len(m.Outputs))
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fisher0x/hashtocrack/internal/modes"
+	"github.com/fisher0x/hashtocrack/internal/workspace"
+)
+
+// openWorkspace returns the workspace of a run: -workspace, or the workspace
+// containing the current directory. It returns nil outside a workspace.
+func openWorkspace(opts *Options) *workspace.Workspace {
+	var ws *workspace.Workspace
+	var err error
+	if opts.Workspace != "" {
+		var root string
+		if root, err = filepath.Abs(opts.Workspace); err == nil {
+			ws, err = workspace.Load(root)
+		}
+	} else if ws, err = workspace.Find("."); err == workspace.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening workspace: %v\n", err)
+		os.Exit(1)
+	}
+	return ws
+}
+
+// workspaceInputs resolves the arguments missing from the command line to
+// the latest workspace file of their role
+func (c *command) workspaceInputs(ws *workspace.Workspace, positional []string) []string {
+	for i := len(positional); i < len(c.inputs); i++ {
+		path, err := ws.Latest(c.inputs[i])
+		if err != nil {
+			usageError(c, "missing <%s>: %v", c.argName(i), err)
+		}
+		fmt.Fprintf(os.Stderr, "[+] Using latest %s: %s\n", c.inputs[i], ws.Rel(path))
+		positional = append(positional, path)
+	}
+	return positional
+}
+
+// outputExt returns the file extension of an output in the given format
+func outputExt(opts *Options) string {
+	ext := ".txt"
+	switch opts.Format {
+	case modes.FormatJSON:
+		ext = ".json"
+	case modes.FormatJSONL:
+		ext = ".jsonl"
+	case modes.FormatHTML:
+		ext = ".html"
+	case modes.FormatMarkdown:
+		ext = ".md"
+	case modes.FormatAsciiDoc:
+		ext = ".adoc"
+	case modes.FormatXLSX:
+		ext = ".xlsx"
+	}
+	switch strings.ToLower(filepath.Ext(opts.Template)) {
+	case ".html", ".htm":
+		ext = ".html"
+	}
+	if opts.Encrypt || len(opts.Recipients) > 0 {
+		ext += ".enc"
+	}
+	return ext
+}
+
+// recordRun adds a finished run, its inputs and its output to the workspace
+func (c *command) recordRun(ws *workspace.Workspace, opts *Options, started time.Time) {
+	run := workspace.Run{
+		Command:    c.name,
+		StartedAt:  started,
+		FinishedAt: time.Now().UTC(),
+		Inputs:     []workspace.File{},
+		Outputs:    []workspace.File{},
+	}
+	for i, role := range c.inputs {
+		paths := []string{opts.NTDSFile}
+		if i == len(c.args)-1 && c.variadic() {
+			paths = opts.Inputs
+		} else if i == 1 {
+			paths = []string{opts.CrackFile}
+		}
+		for _, path := range paths {
+			run.Inputs = append(run.Inputs, workspace.File{Role: role, Path: path})
+		}
+	}
+	run.Outputs = append(run.Outputs, workspace.File{Role: c.output, Path: opts.OutFile})
+	if path := manifestPath(opts); path != "" {
+		run.Manifest = path
+	}
+
+	if err := ws.AddRun(run); err != nil {
+		fmt.Fprintf(os.Stderr, "Error recording run in workspace: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// RunInit executes the init command
+func RunInit(opts *Options) {
+	ws, err := workspace.Init(opts.NTDSFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating workspace: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "[+] Workspace created: %s\n", ws.Root)
+	fmt.Fprintf(os.Stderr, "    Add inputs with 'HashToCrack workspace add ntds|potfile <file>', or place them in\n")
+	fmt.Fprintf(os.Stderr, "    ntds/ and potfiles/ and name them once on the command line.\n")
+}
+
+// runWorkspace executes the workspace command
+func runWorkspace(args []string, version string) {
+	self := findCommand("workspace")
+	if len(args) == 0 {
+		usageError(self, "missing action (status, add)")
+	}
+	action := args[0]
+	switch action {
+	case "-h", "-help", "--help":
+		PrintCommandHelp(self.name)
+		os.Exit(0)
+	case "status", "add":
+	default:
+		usageError(self, "unknown action '%s' (status, add)", action)
+	}
+
+	opts, _, positional := self.resolve(args[1:])
+	ws := openWorkspace(opts)
+	if ws == nil {
+		fmt.Fprintf(os.Stderr, "Error: not inside a workspace (see 'HashToCrack init' or -workspace)\n")
+		os.Exit(1)
+	}
+
+	if action == "status" {
+		if len(positional) > 0 {
+			usageError(self, "unexpected argument '%s'", positional[0])
+		}
+		printWorkspace(ws)
+		return
+	}
+
+	if len(positional) < 2 {
+		usageError(self, "usage: workspace add <role> <file>...")
+	}
+	role := positional[0]
+	if workspace.Dir(role) == "" {
+		var roles []string
+		for _, r := range workspace.Roles {
+			roles = append(roles, r.Role)
+		}
+		usageError(self, "unknown role '%s' (expected %s)", role, strings.Join(roles, ", "))
+	}
+	for _, path := range positional[1:] {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var added []workspace.File
+	err := ws.Update(func() {
+		for _, path := range positional[1:] {
+			added = append(added, ws.Add(role, path, 0))
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing workspace: %v\n", err)
+		os.Exit(1)
+	}
+	for _, file := range added {
+		fmt.Fprintf(os.Stderr, "[+] Added %s: %s\n", role, file.Path)
+	}
+}
+
+// printWorkspace prints the latest file of each role and the runs
+func printWorkspace(ws *workspace.Workspace) {
+	fmt.Printf("Workspace: %s (%s), created %s\n\n", ws.Name, ws.Root, ws.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+
+	fmt.Println("Latest files:")
+	for _, r := range workspace.Roles {
+		latest := "(none)"
+		if path, err := ws.Latest(r.Role); err == nil {
+			latest = ws.Rel(path)
+		}
+		fmt.Printf("  %-11s %s\n", r.Role, latest)
+	}
+
+	fmt.Printf("\nRuns: %d\n", len(ws.Runs))
+	for _, run := range ws.Runs {
+		var inputs, outputs []string
+		for _, f := range run.Inputs {
+			inputs = append(inputs, f.Path)
+		}
+		for _, f := range run.Outputs {
+			outputs = append(outputs, f.Path)
+		}
+		fmt.Printf("  #%-3d %s  %-9s %s -> %s\n", run.ID, run.StartedAt.Format("2006-01-02 15:04:05"), run.Command,
+			strings.Join(inputs, ", "), strings.Join(outputs, ", "))
+	}
+}
+
+func workspaceFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.Workspace, "workspace", "", "Workspace `directory` (default: the workspace containing the\ncurrent directory)")
+}
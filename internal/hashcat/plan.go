@@ -0,0 +1,154 @@
+// Package hashcat describes attack plans and builds the hashcat command
+// lines running them against NT hashes.
+package hashcat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// HashMode is the hashcat hash mode of NT hashes
+const HashMode = "1000"
+
+// Stage types
+const (
+	StageWordlist = "wordlist"
+	StageRules    = "rules"
+	StageMask     = "mask"
+)
+
+// Plan is an ordered list of attack stages
+type Plan struct {
+	// Args are extra hashcat arguments for every stage, e.g. ["-O", "-w", "3"]
+	Args   []string `json:"args"`
+	Stages []Stage  `json:"stages"`
+}
+
+// Stage is one hashcat attack
+type Stage struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Wordlists are the dictionaries of wordlist and rules stages
+	Wordlists []string `json:"wordlists"`
+	// Rules are the rule files of rules stages
+	Rules []string `json:"rules"`
+	// Mask is the mask of mask stages, e.g. "?u?l?l?l?l?d?d?s"
+	Mask string `json:"mask"`
+	// Increment tries every mask length up to the full mask
+	Increment bool `json:"increment"`
+	// Runtime stops the stage after the given number of seconds
+	Runtime int `json:"runtime"`
+	// Args are extra hashcat arguments for this stage
+	Args []string `json:"args"`
+}
+
+// LoadPlan reads and checks a JSON attack plan
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(plan); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(plan.Stages) == 0 {
+		return nil, fmt.Errorf("%s: no stages", path)
+	}
+	for i := range plan.Stages {
+		stage := &plan.Stages[i]
+		if stage.Name == "" {
+			stage.Name = fmt.Sprintf("stage %d (%s)", i+1, stage.Type)
+		}
+		if err := stage.check(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, stage.Name, err)
+		}
+	}
+	return plan, nil
+}
+
+// check rejects stages missing what their type needs
+func (s *Stage) check() error {
+	switch s.Type {
+	case StageWordlist:
+		if len(s.Wordlists) == 0 {
+			return errors.New("wordlist stage needs wordlists")
+		}
+	case StageRules:
+		if len(s.Wordlists) == 0 || len(s.Rules) == 0 {
+			return errors.New("rules stage needs wordlists and rules")
+		}
+	case StageMask:
+		if s.Mask == "" {
+			return errors.New("mask stage needs a mask")
+		}
+	default:
+		return fmt.Errorf("unknown stage type '%s' (expected %s, %s or %s)", s.Type, StageWordlist, StageRules, StageMask)
+	}
+	if s.Runtime < 0 {
+		return fmt.Errorf("invalid runtime %d", s.Runtime)
+	}
+	return nil
+}
+
+// Inputs returns the wordlist and rule files read by the stage
+func (s *Stage) Inputs() []string {
+	return append(append([]string{}, s.Wordlists...), s.Rules...)
+}
+
+// StageArgs returns the hashcat arguments of a stage cracking hashFile into
+// potfile, after the plan's arguments
+func (p *Plan) StageArgs(stage Stage, hashFile, potfile string) []string {
+	args := []string{"-m", HashMode, "--potfile-path", potfile}
+	args = append(args, p.Args...)
+	args = append(args, stage.Args...)
+	if stage.Runtime > 0 {
+		args = append(args, "--runtime", strconv.Itoa(stage.Runtime))
+	}
+
+	switch stage.Type {
+	case StageMask:
+		args = append(args, "-a", "3")
+		if stage.Increment {
+			args = append(args, "--increment")
+		}
+		args = append(args, hashFile, stage.Mask)
+	default:
+		args = append(args, "-a", "0", hashFile)
+		args = append(args, stage.Wordlists...)
+		for _, rule := range stage.Rules {
+			args = append(args, "-r", rule)
+		}
+	}
+	return args
+}
+
+// Exit statuses of hashcat
+var statuses = map[int]string{
+	0: "cracked",
+	1: "exhausted",
+	2: "aborted",
+	3: "aborted by checkpoint",
+	4: "aborted by runtime",
+}
+
+// Status describes a hashcat exit code
+func Status(code int) string {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return "error"
+}
+
+// Completed reports whether an exit code ends a stage normally, so the plan
+// goes on with the next stage
+func Completed(code int) bool {
+	return code == 0 || code == 1 || code == 4
+}
@@ -0,0 +1,86 @@
+package hashcat
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStageArgs(t *testing.T) {
+	plan := &Plan{Args: []string{"-O", "-w", "3"}}
+	tests := []struct {
+		name  string
+		stage Stage
+		want  []string
+	}{
+		{
+			name:  "wordlist",
+			stage: Stage{Type: StageWordlist, Wordlists: []string{"rockyou.txt", "custom.txt"}},
+			want:  []string{"-m", "1000", "--potfile-path", "cracked.pot", "-O", "-w", "3", "-a", "0", "hashes.txt", "rockyou.txt", "custom.txt"},
+		},
+		{
+			name:  "rules",
+			stage: Stage{Type: StageRules, Wordlists: []string{"rockyou.txt"}, Rules: []string{"best64.rule", "d3ad0ne.rule"}, Runtime: 600},
+			want: []string{"-m", "1000", "--potfile-path", "cracked.pot", "-O", "-w", "3", "--runtime", "600",
+				"-a", "0", "hashes.txt", "rockyou.txt", "-r", "best64.rule", "-r", "d3ad0ne.rule"},
+		},
+		{
+			name:  "mask",
+			stage: Stage{Type: StageMask, Mask: "?u?l?l?l?l?d?d?s", Increment: true, Args: []string{"--increment-min", "6"}},
+			want: []string{"-m", "1000", "--potfile-path", "cracked.pot", "-O", "-w", "3", "--increment-min", "6",
+				"-a", "3", "--increment", "hashes.txt", "?u?l?l?l?l?d?d?s"},
+		},
+	}
+	for _, tt := range tests {
+		got := plan.StageArgs(tt.stage, "hashes.txt", "cracked.pot")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: StageArgs = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		// err is a substring of the expected error, "" for a valid plan
+		err string
+	}{
+		{"valid", `{"stages": [{"type": "wordlist", "wordlists": ["a.txt"]}, {"name": "masks", "type": "mask", "mask": "?d?d"}]}`, ""},
+		{"no stages", `{"stages": []}`, "no stages"},
+		{"unknown field", `{"stages": [{"type": "mask", "mask": "?d", "masks": []}]}`, "unknown field"},
+		{"unknown type", `{"stages": [{"type": "hybrid"}]}`, "unknown stage type"},
+		{"wordlist without wordlists", `{"stages": [{"type": "wordlist"}]}`, "needs wordlists"},
+		{"rules without rules", `{"stages": [{"type": "rules", "wordlists": ["a.txt"]}]}`, "needs wordlists and rules"},
+		{"mask without mask", `{"stages": [{"type": "mask"}]}`, "needs a mask"},
+		{"negative runtime", `{"stages": [{"type": "mask", "mask": "?d", "runtime": -1}]}`, "invalid runtime"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, "plan.json")
+		if err := os.WriteFile(path, []byte(tt.json), 0600); err != nil {
+			t.Fatal(err)
+		}
+		plan, err := LoadPlan(path)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		case tt.err == "":
+			if plan.Stages[0].Name != "stage 1 (wordlist)" || plan.Stages[1].Name != "masks" {
+				t.Errorf("%s: stage names %q, %q", tt.name, plan.Stages[0].Name, plan.Stages[1].Name)
+			}
+		}
+	}
+}
+
+func TestCompleted(t *testing.T) {
+	for code, want := range map[int]bool{0: true, 1: true, 2: false, 3: false, 4: true, 255: false, -1: false} {
+		if got := Completed(code); got != want {
+			t.Errorf("Completed(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package manifest records the evidence manifest of a run: the tool version,
+// options, operator and host, and the SHA-256 of every file read and written,
+// so engagement records can later show that evidence files are unchanged.
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/fisher0x/hashtocrack/internal/seal"
+)
+
+// Tool identifies manifests written by HashToCrack
+const Tool = "HashToCrack"
+
+// Manifest is the chain-of-custody record of one run
+type Manifest struct {
+	Tool       string      `json:"tool"`
+	Version    string      `json:"version"`
+	Command    string      `json:"command"`
+	Options    interface{} `json:"options"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Hostname   string      `json:"hostname"`
+	Operator   string      `json:"operator"`
+	Inputs     []File      `json:"inputs"`
+	Outputs    []File      `json:"outputs"`
+	Counts     *Counts     `json:"counts,omitempty"`
+}
+
+// File is the digest of an input or output file. Encrypted files are
+// hashed as stored, so the digest covers the ciphertext.
+type File struct {
+	Role   string `json:"role"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Counts records how many account records a run handled
+type Counts struct {
+	// Processed is the number of accounts included in the output
+	Processed int `json:"processed"`
+	// Skipped is the number of accounts excluded by filters plus the
+	// non-blank lines that could not be parsed
+	Skipped int `json:"skipped"`
+	// Excluded is the number of accounts left out because their NT hash is
+	// already cracked (extract -exclude-pot); they are neither processed nor
+	// skipped
+	Excluded int `json:"excluded,omitempty"`
+}
+
+// New starts the manifest of a run. The operator defaults to the current
+// user name.
+func New(command, version string, options interface{}, operator string) *Manifest {
+	hostname, _ := os.Hostname()
+	if operator == "" {
+		operator = currentUser()
+	}
+	return &Manifest{
+		Tool:      Tool,
+		Version:   version,
+		Command:   command,
+		Options:   options,
+		StartedAt: time.Now().UTC(),
+		Hostname:  hostname,
+		Operator:  operator,
+		Inputs:    []File{},
+		Outputs:   []File{},
+	}
+}
+
+// currentUser returns the login name of the current user, if known
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// AddInput records the digest of a file read by the run. It does nothing on
+// a nil manifest.
+func (m *Manifest) AddInput(role, path string) error {
+	if m == nil {
+		return nil
+	}
+	file, err := digest(role, path)
+	if err != nil {
+		return err
+	}
+	m.Inputs = append(m.Inputs, file)
+	return nil
+}
+
+// AddOutput records the digest of a file written by the run, once it is
+// complete. It does nothing on a nil manifest.
+func (m *Manifest) AddOutput(role, path string) error {
+	if m == nil {
+		return nil
+	}
+	file, err := digest(role, path)
+	if err != nil {
+		return err
+	}
+	m.Outputs = append(m.Outputs, file)
+	return nil
+}
+
+// SetCounts records the account counts. It does nothing on a nil manifest.
+func (m *Manifest) SetCounts(processed, skipped int) {
+	if m == nil {
+		return
+	}
+	m.Counts = &Counts{Processed: processed, Skipped: skipped}
+}
+
+// SetExcluded records the accounts excluded as already cracked, after
+// SetCounts. It does nothing on a nil manifest.
+func (m *Manifest) SetExcluded(excluded int) {
+	if m == nil || m.Counts == nil {
+		return
+	}
+	m.Counts.Excluded = excluded
+}
+
+// Finish records the end of the run
+func (m *Manifest) Finish() {
+	finished := time.Now().UTC()
+	m.FinishedAt = &finished
+}
+
+// Write writes the manifest as indented JSON to a private file
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	output, err := seal.CreatePlain(path)
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(append(data, '\n'))
+	return output.Finish(err)
+}
+
+// digest hashes a file as stored on disk
+func digest(role, path string) (File, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return File{}, err
+	}
+	sum, size, err := hashFile(abs)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Role: role, Path: abs, Size: size, SHA256: sum}, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// htmlMarker starts the manifest embedded in HTML reports
+const htmlMarker = `<script type="application/json" id="hashtocrack-manifest">`
+
+// Load reads a manifest from a sidecar file, or the manifest embedded in a
+// JSON or HTML report. Encrypted files are decrypted with the configured keys.
+func Load(path string) (*Manifest, error) {
+	data, err := seal.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if start := bytes.Index(data, []byte(htmlMarker)); start >= 0 {
+		data = data[start+len(htmlMarker):]
+		end := bytes.Index(data, []byte("</script>"))
+		if end < 0 {
+			return nil, errors.New("unterminated embedded manifest")
+		}
+		data = data[:end]
+	}
+
+	var doc struct {
+		Manifest
+		Embedded *Manifest `json:"manifest"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("not a manifest or report with an embedded manifest: %v", err)
+	}
+	switch {
+	case doc.Tool == Tool:
+		return &doc.Manifest, nil
+	case doc.Embedded != nil && doc.Embedded.Tool == Tool:
+		return doc.Embedded, nil
+	}
+	return nil, errors.New("no HashToCrack manifest found")
+}
+
+// Verification statuses
+const (
+	StatusOK       = "ok"
+	StatusModified = "modified"
+	StatusMissing  = "missing"
+)
+
+// Check is the verification result of one recorded file
+type Check struct {
+	File
+	Status string
+	// FoundAt is where the file was found when it was no longer at its
+	// recorded path
+	FoundAt string
+	Err     error
+}
+
+// Verify re-hashes every recorded file. A file missing from its recorded
+// path is also looked for by name in dir, so evidence moved together with
+// its manifest still verifies.
+func (m *Manifest) Verify(dir string) []Check {
+	var checks []Check
+	for _, files := range [][]File{m.Inputs, m.Outputs} {
+		for _, file := range files {
+			checks = append(checks, verifyFile(file, dir))
+		}
+	}
+	return checks
+}
+
+func verifyFile(file File, dir string) Check {
+	check := Check{File: file, Status: StatusMissing}
+
+	path := file.Path
+	if _, err := os.Stat(path); err != nil {
+		moved := filepath.Join(dir, filepath.Base(file.Path))
+		if _, err := os.Stat(moved); err != nil {
+			return check
+		}
+		path, check.FoundAt = moved, moved
+	}
+
+	sum, _, err := hashFile(path)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	if sum == file.SHA256 {
+		check.Status = StatusOK
+	} else {
+		check.Status = StatusModified
+	}
+	return check
+}
@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteLoadVerify(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	files := map[string]string{"ntds.txt": "ntds", "potfile.txt": "pot", "matched.txt": "matched", "report.txt": "report"}
+	for name, content := range files {
+		if err := os.WriteFile(path(name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := New("match", "1.0.0", map[string]bool{"disabled": true}, "analyst")
+	for _, input := range []string{"ntds.txt", "potfile.txt"} {
+		if err := m.AddInput("input", path(input)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, output := range []string{"matched.txt", "report.txt"} {
+		if err := m.AddOutput("output", path(output)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddInput("input", path("missing.txt")); err == nil {
+		t.Error("missing input recorded")
+	}
+	m.SetCounts(10, 2)
+	m.SetExcluded(3)
+	m.Finish()
+	if err := m.Write(path("run.manifest.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path("run.manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Tool != Tool || loaded.Command != "match" || loaded.Operator != "analyst" || loaded.FinishedAt == nil {
+		t.Errorf("loaded manifest: %+v", loaded)
+	}
+	if *loaded.Counts != (Counts{Processed: 10, Skipped: 2, Excluded: 3}) {
+		t.Errorf("counts: %+v", *loaded.Counts)
+	}
+	sum := sha256.Sum256([]byte("ntds"))
+	if in := loaded.Inputs[0]; in.Path != path("ntds.txt") || in.Size != 4 || in.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("input digest: %+v", in)
+	}
+
+	// Modify one output, delete an input and move the other to another directory
+	if err := os.WriteFile(path("report.txt"), []byte("edited"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path("potfile.txt")); err != nil {
+		t.Fatal(err)
+	}
+	moved := t.TempDir()
+	if err := os.Rename(path("ntds.txt"), filepath.Join(moved, "ntds.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"ntds.txt": StatusOK, "potfile.txt": StatusMissing, "matched.txt": StatusOK, "report.txt": StatusModified}
+	for _, check := range loaded.Verify(moved) {
+		name := filepath.Base(check.Path)
+		if check.Status != want[name] {
+			t.Errorf("%s: %s, want %s", name, check.Status, want[name])
+		}
+		if name == "ntds.txt" && check.FoundAt != filepath.Join(moved, "ntds.txt") {
+			t.Errorf("moved input found at %q", check.FoundAt)
+		}
+	}
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	m := New("analyze", "1.0.0", nil, "analyst")
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	reports := map[string]string{
+		"report.json": `{"total_accounts": 3, "manifest": ` + string(data) + `}`,
+		"report.html": "<html><body>" + htmlMarker + string(data) + "</script></body></html>",
+	}
+	for name, content := range reports {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if loaded.Command != "analyze" {
+			t.Errorf("%s: command %q", name, loaded.Command)
+		}
+	}
+
+	for name, content := range map[string]string{
+		"other.json":     `{"tool": "other"}`,
+		"truncated.html": htmlMarker + string(data),
+		"text.txt":       "not json",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s loaded as a manifest", name)
+		}
+	}
+}
+
+func TestNilManifest(t *testing.T) {
+	var m *Manifest
+	if err := m.AddInput("input", "missing.txt"); err != nil {
+		t.Error(err)
+	}
+	if err := m.AddOutput("output", "missing.txt"); err != nil {
+		t.Error(err)
+	}
+	m.SetCounts(1, 1)
+	m.SetExcluded(1)
+}
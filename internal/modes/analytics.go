@@ -1,22 +1,30 @@
 package modes
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
 	"unicode"
 
+	"github.com/fisher0x/hashtocrack/internal/manifest"
 	"github.com/fisher0x/hashtocrack/internal/ntds"
-	"github.com/fisher0x/hashtocrack/internal/utils"
+	"github.com/fisher0x/hashtocrack/internal/redact"
+	"github.com/fisher0x/hashtocrack/internal/seal"
 )
 
 // isComplexPassword checks if password meets DOMAIN_PASSWORD_COMPLEX requirements
 // Requirements: >= 8 chars, 3 of 4 categories (upper, lower, digit, special)
 func isComplexPassword(password string) bool {
+	return len(policyFailures(password)) == 0
+}
+
+// policyFailures lists the DOMAIN_PASSWORD_COMPLEX requirements a password fails
+func policyFailures(password string) []string {
+	var failures []string
 	if len(password) < 8 {
-		return false
+		failures = append(failures, fmt.Sprintf("too short (%d < 8)", len(password)))
 	}
 
 	hasUpper := false
@@ -51,244 +59,346 @@ func isComplexPassword(password string) bool {
 		categories++
 	}
 
-	return categories >= 3
+	if categories < 3 {
+		failures = append(failures, fmt.Sprintf("%d of 4 character categories", categories))
+	}
+	return failures
 }
 
-// redactPassword returns a redacted version of the password
-// Shows first 3 characters, replaces the rest with asterisks
-func redactPassword(password string) string {
-	if len(password) <= 3 {
-		return password
-	}
-	return password[:3] + strings.Repeat("*", len(password)-3)
+// AnalyticsOptions holds the settings for analytics mode
+type AnalyticsOptions struct {
+	InputFile       string
+	OutFile         string
+	IncludeDisabled bool
+	IncludeMachines bool
+	ShowPasspol     bool
+	// Redactor redacts every disclosed password when set
+	Redactor        *redact.Redactor
+	ShowPairs       bool
+	PairPatterns    []string
+	PairMaxDistance int
+	GroupFiles      []string
+	ExtraPrivileged []string
+	// MinShare discloses a password only when at least this many accounts use it
+	MinShare     int
+	Format       string
+	TemplateFile string
+	ChartsDir    string
+	// Manifest is embedded in the report and records the run's counts and
+	// charts when set
+	Manifest *manifest.Manifest
 }
 
 // RunAnalytics generates statistics from matched file
-func RunAnalytics(analyticsFile, outfile string, includeDisabled, includeMachines, showPasspol, redactPasswords bool) {
-	file, err := os.Open(analyticsFile)
+func RunAnalytics(opts AnalyticsOptions) {
+	entries, err := loadAnalyticsEntries(opts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	defer file.Close()
 
-	var output *os.File
-	if outfile != "" {
-		if err := utils.EnsureDir(outfile); err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
-			os.Exit(1)
+	// A workbook is meant to be shared, so its passwords are redacted by
+	// default, as in the match workbook
+	if opts.Redactor == nil && opts.Format == FormatXLSX && opts.TemplateFile == "" {
+		opts.Redactor = redact.Default()
+	}
+
+	result := ComputeAnalytics(entries, opts)
+	if opts.Redactor != nil {
+		redactResult(result, opts.Redactor)
+	}
+
+	err = writeOutput(opts.OutFile, func(output io.Writer) error {
+		if opts.TemplateFile != "" {
+			return writeUserTemplateReport(output, result, opts)
 		}
-		output, err = os.Create(outfile)
+		return writeFormattedReport(output, result, opts)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.OutFile != "" {
+		fmt.Fprintf(os.Stderr, "[+] Analytics report written to: %s\n", opts.OutFile)
+	}
+
+	if opts.ChartsDir != "" {
+		count, err := writeCharts(opts.ChartsDir, result, opts)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error writing charts: %v\n", err)
 			os.Exit(1)
 		}
-		defer output.Close()
+		fmt.Fprintf(os.Stderr, "[+] %d chart files written to: %s\n", count, opts.ChartsDir)
 	}
+}
 
-	// Collect statistics
-	totalAccounts := 0
-	crackedAccounts := 0
-	complexCount := 0
-	lengthDist := make(map[int]int)
-	passwordCounts := make(map[string]int)
+// analyticsReport is the JSON analytics report: the result, followed by the
+// evidence manifest of the run when one is written
+type analyticsReport struct {
+	*ntds.AnalyticsResult
+	Manifest *manifest.Manifest `json:"manifest,omitempty"`
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		entry, err := ntds.ParseAnalyticsLine(scanner.Text())
+// writeFormattedReport writes the analytics result in one of the built-in formats
+func writeFormattedReport(output io.Writer, result *ntds.AnalyticsResult, opts AnalyticsOptions) error {
+	switch opts.Format {
+	case FormatJSON, FormatJSONL:
+		return writeJSON(output, opts.Format, analyticsReport{result, opts.Manifest})
+	case FormatHTML:
+		return writeHTMLReport(output, result, opts)
+	case FormatMarkdown, FormatAsciiDoc:
+		return writeMarkupReport(output, result, opts)
+	case FormatXLSX:
+		return writeXLSXReport(output, result, opts)
+	default:
+		return writeTextReport(output, result, opts)
+	}
+}
+
+// loadAnalyticsEntries reads the matched file, applying the account filters
+// and tagging privileged accounts when group membership files are given
+func loadAnalyticsEntries(opts AnalyticsOptions) ([]*ntds.CrackedEntry, error) {
+	file, err := seal.Open(opts.InputFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %v", err)
+	}
+	defer file.Close()
+
+	var membership *ntds.GroupMembership
+	if len(opts.GroupFiles) > 0 {
+		membership, err = ntds.LoadGroupMembership(opts.GroupFiles, opts.ExtraPrivileged)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("loading group membership: %v", err)
 		}
+	}
+
+	var records []*ntds.CrackedEntry
+	scanner := ntds.NewMatchedScanner(file)
+	for scanner.Scan() {
+		records = append(records, scanner.Entry())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading file: %v", err)
+	}
+	if scanner.Redaction() != "" {
+		return nil, fmt.Errorf("%s holds passwords redacted by match (%s): use the plaintext matched file, and -report to redact the output", opts.InputFile, scanner.Redaction())
+	}
 
+	var entries []*ntds.CrackedEntry
+	filtered := 0
+	for _, entry := range ntds.LatestRecords(records) {
 		// Apply filters
-		if entry.IsDisabled && !includeDisabled {
+		if entry.IsDisabled && !opts.IncludeDisabled {
+			filtered++
 			continue
 		}
-		if entry.IsMachine && !includeMachines {
+		if entry.IsMachine && !opts.IncludeMachines {
+			filtered++
 			continue
 		}
 
-		totalAccounts++
+		if membership != nil {
+			membership.Tag(&entry.Entry)
+		}
+		entries = append(entries, entry)
+	}
 
+	opts.Manifest.SetCounts(len(entries), filtered+scanner.Skipped())
+	return entries, nil
+}
+
+// ComputeAnalytics builds the analytics result for the filtered entries
+func ComputeAnalytics(entries []*ntds.CrackedEntry, opts AnalyticsOptions) *ntds.AnalyticsResult {
+	result := &ntds.AnalyticsResult{
+		InputFile:          opts.InputFile,
+		IncludeDisabled:    opts.IncludeDisabled,
+		IncludeMachines:    opts.IncludeMachines,
+		LengthDistribution: make(map[int]int),
+		TopPasswords:       []ntds.PasswordCount{},
+		TopMasks:           []ntds.MaskCount{},
+		Accounts:           []ntds.AccountSummary{},
+	}
+
+	result.Accounts = append(result.Accounts, AccountSummaries(entries)...)
+
+	passwordCounts := make(map[string]int)
+	maskCounts := make(map[string]int)
+	for _, entry := range entries {
+		result.TotalAccounts++
 		if entry.Cracked {
-			crackedAccounts++
-			lengthDist[len(entry.Password)]++
+			result.CrackedAccounts++
+			result.LengthDistribution[len(entry.Password)]++
 			passwordCounts[entry.Password]++
+			maskCounts[PasswordMask(entry.Password)]++
 
 			if isComplexPassword(entry.Password) {
-				complexCount++
+				result.ComplexCount++
 			}
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-		os.Exit(1)
-	}
+	result.CrackPercentage = percentage(result.CrackedAccounts, result.TotalAccounts)
+	result.ComplexPercentage = percentage(result.ComplexCount, result.CrackedAccounts)
 
-	// Calculate percentages
-	crackPct := 0.0
-	if totalAccounts > 0 {
-		crackPct = float64(crackedAccounts) / float64(totalAccounts) * 100
+	// Get top 10 passwords and masks. Below the k-anonymity threshold a
+	// password, or a mask giving its structure, points to too few accounts.
+	result.Suppressed = &ntds.SuppressedStats{MinShare: opts.MinShare}
+	for pwd, count := range passwordCounts {
+		if count < opts.MinShare {
+			result.Suppressed.Passwords++
+			result.Suppressed.Accounts += count
+			continue
+		}
+		result.TopPasswords = append(result.TopPasswords, ntds.PasswordCount{Password: pwd, Count: count})
+	}
+	sort.Slice(result.TopPasswords, func(i, j int) bool {
+		if result.TopPasswords[i].Count != result.TopPasswords[j].Count {
+			return result.TopPasswords[i].Count > result.TopPasswords[j].Count
+		}
+		return result.TopPasswords[i].Password < result.TopPasswords[j].Password
+	})
+	if len(result.TopPasswords) > 10 {
+		result.TopPasswords = result.TopPasswords[:10]
 	}
 
-	complexPct := 0.0
-	if crackedAccounts > 0 {
-		complexPct = float64(complexCount) / float64(crackedAccounts) * 100
+	for mask, count := range maskCounts {
+		if count < opts.MinShare {
+			result.Suppressed.Masks++
+			result.Suppressed.MaskAccounts += count
+			continue
+		}
+		result.TopMasks = append(result.TopMasks, ntds.MaskCount{Mask: mask, Count: count})
+	}
+	sort.Slice(result.TopMasks, func(i, j int) bool {
+		if result.TopMasks[i].Count != result.TopMasks[j].Count {
+			return result.TopMasks[i].Count > result.TopMasks[j].Count
+		}
+		return result.TopMasks[i].Mask < result.TopMasks[j].Mask
+	})
+	if len(result.TopMasks) > 10 {
+		result.TopMasks = result.TopMasks[:10]
 	}
 
-	// Get top 10 passwords
-	type pwdCount struct {
-		Password string
-		Count    int
+	if opts.ShowPairs {
+		result.Pairs = FindAccountPairs(entries, opts.PairPatterns, opts.PairMaxDistance)
 	}
-	var passwords []pwdCount
-	for pwd, count := range passwordCounts {
-		passwords = append(passwords, pwdCount{pwd, count})
+	if len(opts.GroupFiles) > 0 {
+		result.Privileged = ComputePrivilegedStats(entries)
 	}
-	sort.Slice(passwords, func(i, j int) bool {
-		return passwords[i].Count > passwords[j].Count
-	})
-	if len(passwords) > 10 {
-		passwords = passwords[:10]
+	if opts.MinShare > 1 {
+		suppressRarePasswords(result, passwordCounts, opts.MinShare)
 	}
 
-	// Sort length distribution
-	var lengths []int
-	for length := range lengthDist {
-		lengths = append(lengths, length)
+	return result
+}
+
+// AccountSummaries builds the per-account lines for entries, with policy
+// failures for cracked passwords and reuse clusters of shared NT hashes
+func AccountSummaries(entries []*ntds.CrackedEntry) []ntds.AccountSummary {
+	hashUsers := make(map[string]int)
+	for _, entry := range entries {
+		hashUsers[strings.ToLower(entry.NTHash)]++
 	}
-	sort.Ints(lengths)
 
-	// Generate report
-	writeFunc := func(format string, args ...interface{}) {
-		line := fmt.Sprintf(format, args...)
-		if output != nil {
-			fmt.Fprint(output, line)
-		} else {
-			fmt.Print(line)
+	// Number the shared hashes by cluster size, then hash for a stable order
+	var shared []string
+	for hash, count := range hashUsers {
+		if count > 1 {
+			shared = append(shared, hash)
 		}
 	}
-
-	writeFunc("\n")
-	writeFunc("╔══════════════════════════════════════════════════════════════╗\n")
-	writeFunc("║           HASHTOCRACK - Password Analytics Report           ║\n")
-	writeFunc("╚══════════════════════════════════════════════════════════════╝\n\n")
-
-	// Filters applied
-	writeFunc("Filters Applied:\n")
-	writeFunc("  • Disabled accounts: %s\n", utils.BoolToIncluded(includeDisabled))
-	writeFunc("  • Machine accounts:  %s\n", utils.BoolToIncluded(includeMachines))
-	writeFunc("\n")
-
-	// General Statistics
-	writeFunc("═══════════════════════════════════════════════════════════════\n")
-	writeFunc("                      GENERAL STATISTICS                        \n")
-	writeFunc("═══════════════════════════════════════════════════════════════\n\n")
-	writeFunc("  Total Accounts Analyzed:  %d\n", totalAccounts)
-	writeFunc("  Passwords Cracked:        %d (%.2f%%)\n", crackedAccounts, crackPct)
-	writeFunc("  Passwords Not Cracked:    %d (%.2f%%)\n", totalAccounts-crackedAccounts, 100-crackPct)
-	writeFunc("\n")
-
-	// Progress bar
-	barWidth := 40
-	filled := int(crackPct / 100 * float64(barWidth))
-	writeFunc("  Crack Progress: [")
-	for i := 0; i < barWidth; i++ {
-		if i < filled {
-			writeFunc("█")
-		} else {
-			writeFunc("░")
+	sort.Slice(shared, func(i, j int) bool {
+		if hashUsers[shared[i]] != hashUsers[shared[j]] {
+			return hashUsers[shared[i]] > hashUsers[shared[j]]
 		}
+		return shared[i] < shared[j]
+	})
+	clusters := make(map[string]int)
+	for i, hash := range shared {
+		clusters[hash] = i + 1
 	}
-	writeFunc("] %.1f%%\n\n", crackPct)
 
-	// Password Length Distribution
-	writeFunc("═══════════════════════════════════════════════════════════════\n")
-	writeFunc("                  PASSWORD LENGTH DISTRIBUTION                  \n")
-	writeFunc("═══════════════════════════════════════════════════════════════\n\n")
+	summaries := make([]ntds.AccountSummary, 0, len(entries))
+	for _, entry := range entries {
+		summary := ntds.AccountSummary{
+			Username:         entry.Username,
+			Status:           entry.Status(),
+			Cracked:          entry.Cracked,
+			Password:         entry.Password,
+			Length:           len(entry.Password),
+			PrivilegedGroups: entry.PrivilegedGroups,
+			ReuseCluster:     clusters[strings.ToLower(entry.NTHash)],
+		}
+		if entry.Cracked {
+			summary.PolicyFailures = policyFailures(entry.Password)
+			summary.Compliant = len(summary.PolicyFailures) == 0
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
 
-	if len(lengths) > 0 {
-		maxCount := 0
-		for _, count := range lengthDist {
-			if count > maxCount {
-				maxCount = count
-			}
+// suppressRarePasswords replaces every disclosed password used by fewer than
+// minShare accounts, so that no password identifies a single person
+func suppressRarePasswords(result *ntds.AnalyticsResult, passwordCounts map[string]int, minShare int) {
+	suppress := func(password *string) {
+		if passwordCounts[*password] < minShare {
+			*password = ntds.SuppressedPassword
 		}
+	}
 
-		for _, length := range lengths {
-			count := lengthDist[length]
-			pct := float64(count) / float64(crackedAccounts) * 100
-			barLen := int(float64(count) / float64(maxCount) * 30)
-			bar := strings.Repeat("▓", barLen)
-			writeFunc("  %2d chars: %-30s %4d (%5.1f%%)\n", length, bar, count, pct)
+	for i := range result.Accounts {
+		if result.Accounts[i].Cracked {
+			suppress(&result.Accounts[i].Password)
 		}
-	} else {
-		writeFunc("  No cracked passwords to analyze.\n")
-	}
-	writeFunc("\n")
-
-	// Top 10 Passwords
-	writeFunc("═══════════════════════════════════════════════════════════════\n")
-	writeFunc("                    TOP 10 MOST USED PASSWORDS                  \n")
-	writeFunc("═══════════════════════════════════════════════════════════════\n\n")
-
-	if len(passwords) > 0 {
-		writeFunc("  %-4s  %-30s  %s\n", "Rank", "Password", "Count")
-		writeFunc("  ────  ──────────────────────────────  ─────\n")
-		for i, pwd := range passwords {
-			displayPwd := pwd.Password
-			if redactPasswords {
-				displayPwd = redactPassword(displayPwd)
-			}
-			if len(displayPwd) > 28 {
-				displayPwd = displayPwd[:25] + "..."
-			}
-			writeFunc("  #%-3d  %-30s  %d\n", i+1, displayPwd, pwd.Count)
+	}
+	for i := range result.Pairs {
+		if result.Pairs[i].Kind == ntds.PairSimilarPassword {
+			suppress(&result.Pairs[i].UserPassword)
+			suppress(&result.Pairs[i].AdminPassword)
 		}
-	} else {
-		writeFunc("  No cracked passwords to analyze.\n")
-	}
-	writeFunc("\n")
-
-	// Password Policy Compliance
-	if showPasspol {
-		writeFunc("═══════════════════════════════════════════════════════════════\n")
-		writeFunc("              PASSWORD POLICY COMPLIANCE ANALYSIS               \n")
-		writeFunc("═══════════════════════════════════════════════════════════════\n\n")
-
-		writeFunc("  Policy: DOMAIN_PASSWORD_COMPLEX\n")
-		writeFunc("  Requirements:\n")
-		writeFunc("    • Minimum 8 characters\n")
-		writeFunc("    • At least 3 of 4 categories:\n")
-		writeFunc("      - Uppercase letters (A-Z)\n")
-		writeFunc("      - Lowercase letters (a-z)\n")
-		writeFunc("      - Digits (0-9)\n")
-		writeFunc("      - Special characters (!@#$%%^&*...)\n\n")
-
-		writeFunc("  Results:\n")
-		writeFunc("    Compliant passwords:     %d (%.2f%% of cracked)\n", complexCount, complexPct)
-		writeFunc("    Non-compliant passwords: %d (%.2f%% of cracked)\n", crackedAccounts-complexCount, 100-complexPct)
-		writeFunc("\n")
-
-		// Compliance bar
-		writeFunc("  Compliance: [")
-		compFilled := int(complexPct / 100 * float64(barWidth))
-		for i := 0; i < barWidth; i++ {
-			if i < compFilled {
-				writeFunc("█")
-			} else {
-				writeFunc("░")
-			}
+	}
+	if result.Privileged != nil {
+		for i := range result.Privileged.CrackedAccounts {
+			suppress(&result.Privileged.CrackedAccounts[i].Password)
 		}
-		writeFunc("] %.1f%%\n\n", complexPct)
 	}
+}
 
-	writeFunc("═══════════════════════════════════════════════════════════════\n")
-	writeFunc("                        END OF REPORT                           \n")
-	writeFunc("═══════════════════════════════════════════════════════════════\n")
-
-	if outfile != "" {
-		fmt.Fprintf(os.Stderr, "[+] Analytics report written to: %s\n", outfile)
+// redactResult redacts every password disclosed in the result
+func redactResult(result *ntds.AnalyticsResult, redactor *redact.Redactor) {
+	redactPassword := func(password string) string {
+		if password == ntds.SuppressedPassword {
+			return password
+		}
+		return redactor.Redact(password)
+	}
+	result.Redacted = true
+	result.Redaction = redactor.Description()
+	for i := range result.TopPasswords {
+		result.TopPasswords[i].Password = redactPassword(result.TopPasswords[i].Password)
+	}
+	for i := range result.Pairs {
+		if result.Pairs[i].Kind == ntds.PairSimilarPassword {
+			result.Pairs[i].UserPassword = redactPassword(result.Pairs[i].UserPassword)
+			result.Pairs[i].AdminPassword = redactPassword(result.Pairs[i].AdminPassword)
+		}
+		// The shared NT hash authenticates as the admin account (pass-the-hash)
+		result.Pairs[i].NTHash = ""
 	}
+	for i := range result.Accounts {
+		result.Accounts[i].Password = redactPassword(result.Accounts[i].Password)
+	}
+	if result.Privileged != nil {
+		for i := range result.Privileged.CrackedAccounts {
+			account := &result.Privileged.CrackedAccounts[i]
+			account.Password = redactPassword(account.Password)
+		}
+	}
+}
+
+// writeTextReport writes the analytics result as the terminal report
+func writeTextReport(w io.Writer, result *ntds.AnalyticsResult, opts AnalyticsOptions) error {
+	return writeTemplateReport(w, NewReportData(result, opts), "report.txt", defaultTextTemplate, false)
 }
@@ -0,0 +1,74 @@
+package modes
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+)
+
+func TestComputeAnalyticsMinShare(t *testing.T) {
+	var entries []*ntds.CrackedEntry
+	add := func(password string, count int) {
+		for i := 0; i < count; i++ {
+			entries = append(entries, &ntds.CrackedEntry{
+				Entry:    ntds.Entry{Username: fmt.Sprintf(`CORP\user%d`, len(entries))},
+				Password: password,
+				Cracked:  true,
+			})
+		}
+	}
+	// Summer2024! meets a threshold of 3 exactly, Welcome1 is one short and
+	// zz is unique. Each has its own mask.
+	add("Summer2024!", 3)
+	add("Welcome1", 2)
+	add("zz", 1)
+
+	tests := []struct {
+		minShare   int
+		passwords  []ntds.PasswordCount
+		masks      []ntds.MaskCount
+		suppressed ntds.SuppressedStats
+	}{
+		{
+			minShare:   0,
+			passwords:  []ntds.PasswordCount{{Password: "Summer2024!", Count: 3}, {Password: "Welcome1", Count: 2}, {Password: "zz", Count: 1}},
+			masks:      []ntds.MaskCount{{Mask: PasswordMask("Summer2024!"), Count: 3}, {Mask: PasswordMask("Welcome1"), Count: 2}, {Mask: PasswordMask("zz"), Count: 1}},
+			suppressed: ntds.SuppressedStats{},
+		},
+		{
+			minShare:   2,
+			passwords:  []ntds.PasswordCount{{Password: "Summer2024!", Count: 3}, {Password: "Welcome1", Count: 2}},
+			masks:      []ntds.MaskCount{{Mask: PasswordMask("Summer2024!"), Count: 3}, {Mask: PasswordMask("Welcome1"), Count: 2}},
+			suppressed: ntds.SuppressedStats{MinShare: 2, Passwords: 1, Accounts: 1, Masks: 1, MaskAccounts: 1},
+		},
+		{
+			minShare:   3,
+			passwords:  []ntds.PasswordCount{{Password: "Summer2024!", Count: 3}},
+			masks:      []ntds.MaskCount{{Mask: PasswordMask("Summer2024!"), Count: 3}},
+			suppressed: ntds.SuppressedStats{MinShare: 3, Passwords: 2, Accounts: 3, Masks: 2, MaskAccounts: 3},
+		},
+	}
+	passwordCounts := map[string]int{"Summer2024!": 3, "Welcome1": 2, "zz": 1}
+	for _, tt := range tests {
+		result := ComputeAnalytics(entries, AnalyticsOptions{MinShare: tt.minShare})
+		if !reflect.DeepEqual(result.TopPasswords, tt.passwords) {
+			t.Errorf("min-share %d: top passwords %v, want %v", tt.minShare, result.TopPasswords, tt.passwords)
+		}
+		if !reflect.DeepEqual(result.TopMasks, tt.masks) {
+			t.Errorf("min-share %d: top masks %v, want %v", tt.minShare, result.TopMasks, tt.masks)
+		}
+		if result.Suppressed == nil || *result.Suppressed != tt.suppressed {
+			t.Errorf("min-share %d: suppressed %+v, want %+v", tt.minShare, result.Suppressed, tt.suppressed)
+		}
+
+		// Per-account passwords follow the same threshold
+		for i, account := range result.Accounts {
+			disclosed := account.Password != ntds.SuppressedPassword
+			if want := passwordCounts[entries[i].Password] >= tt.minShare; disclosed != want {
+				t.Errorf("min-share %d: %s password %q", tt.minShare, account.Username, account.Password)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package modes
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/anonymize"
+	"github.com/fisher0x/hashtocrack/internal/manifest"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+)
+
+// AnonymizeOptions holds the settings for the anonymize command
+type AnonymizeOptions struct {
+	InputFile    string
+	OutFile      string
+	Secret       []byte
+	PasswordMode string
+	PairPatterns []string
+	// Manifest records the run's counts when set
+	Manifest *manifest.Manifest
+}
+
+// pseudonymizer maps usernames to pseudonyms, keeping admin naming patterns
+// so that admin/user pairs are still detected in the anonymized file
+type pseudonymizer struct {
+	anon     *anonymize.Anonymizer
+	patterns []string
+	// names holds the lowercase account names of each lowercase domain
+	names map[string]map[string]bool
+	cache map[string]string
+}
+
+func newPseudonymizer(anon *anonymize.Anonymizer, patterns []string, entries []*ntds.CrackedEntry) *pseudonymizer {
+	if len(patterns) == 0 {
+		patterns = DefaultPairPatterns
+	}
+	p := &pseudonymizer{
+		anon:     anon,
+		patterns: patterns,
+		names:    make(map[string]map[string]bool),
+		cache:    make(map[string]string),
+	}
+	for _, entry := range entries {
+		domain, name := ntds.SplitUsername(entry.Username)
+		domain = strings.ToLower(domain)
+		if p.names[domain] == nil {
+			p.names[domain] = make(map[string]bool)
+		}
+		p.names[domain][strings.ToLower(name)] = true
+	}
+	return p
+}
+
+// username returns the pseudonym of a DOMAIN\name username
+func (p *pseudonymizer) username(username string) string {
+	domain, name := ntds.SplitUsername(username)
+	pseudonym := p.name(domain, name)
+	if domain == "" {
+		return pseudonym
+	}
+	return p.anon.Domain(domain) + "\\" + pseudonym
+}
+
+// name returns the pseudonym of an account name. An admin account named after
+// another account of the domain gets the same pattern applied to that
+// account's pseudonym (jdoe_adm -> user-1a2b3c4d5e_adm).
+func (p *pseudonymizer) name(domain, name string) string {
+	key := strings.ToLower(domain) + "\\" + strings.ToLower(name)
+	if pseudonym, ok := p.cache[key]; ok {
+		return pseudonym
+	}
+
+	var pseudonym string
+	if strings.HasSuffix(name, "$") {
+		pseudonym = p.anon.Name("host-", strings.TrimSuffix(name, "$")) + "$"
+	} else {
+		for _, pattern := range p.patterns {
+			base, ok := matchPairPattern(pattern, name)
+			if !ok || !p.names[strings.ToLower(domain)][base] {
+				continue
+			}
+			pseudonym = strings.Replace(strings.ToLower(pattern), "{user}", p.name(domain, base), 1)
+			break
+		}
+		if pseudonym == "" {
+			pseudonym = p.anon.Name("user-", name)
+		}
+	}
+
+	p.cache[key] = pseudonym
+	return pseudonym
+}
+
+// RunAnonymize rewrites a matched file with pseudonymous usernames, keyed NT
+// hashes and password tokens, for sharing outside the engagement
+func RunAnonymize(opts AnonymizeOptions) {
+	file, err := seal.Open(opts.InputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		os.Exit(1)
+	}
+
+	var entries []*ntds.CrackedEntry
+	scanner := ntds.NewMatchedScanner(file)
+	for scanner.Scan() {
+		entries = append(entries, scanner.Entry())
+	}
+	file.Close()
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+	// Tokens of redacted strings would pass for passwords once the redacted
+	// header is gone
+	if scanner.Redaction() != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s holds passwords redacted by match (%s): anonymize the plaintext matched file\n", opts.InputFile, scanner.Redaction())
+		os.Exit(1)
+	}
+	entries = ntds.LatestRecords(entries)
+
+	opts.Manifest.SetCounts(len(entries), scanner.Skipped())
+
+	anon := anonymize.New(opts.Secret)
+	names := newPseudonymizer(anon, opts.PairPatterns, entries)
+
+	// Derive the tokens of paired admin accounts from their user account's
+	// token, so similar-password pairs keep their edit distance
+	if opts.PasswordMode == anonymize.PasswordsMask {
+		for _, pair := range FindAccountPairs(entries, opts.PairPatterns, math.MaxInt) {
+			if pair.Kind != ntds.PairSimilarPassword {
+				continue
+			}
+			if _, err := anon.RelatedPassword(pair.AdminPassword, pair.UserPassword); err != nil {
+				fmt.Fprintf(os.Stderr, "Error anonymizing password of %s: %v\n", pair.Admin, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	var records []*ntds.CrackedEntry
+	for _, entry := range entries {
+		password := ""
+		if entry.Cracked {
+			password, err = anon.Password(entry.Password, opts.PasswordMode)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error anonymizing password of %s: %v\n", entry.Username, err)
+				os.Exit(1)
+			}
+		}
+
+		anonymized := &ntds.CrackedEntry{
+			Entry: ntds.Entry{
+				Username:   names.username(entry.Username),
+				NTHash:     anon.NTHash(entry.NTHash),
+				IsDisabled: entry.IsDisabled,
+				IsMachine:  entry.IsMachine,
+				HasLMHash:  entry.HasLMHash,
+			},
+			Password: password,
+			Cracked:  entry.Cracked,
+		}
+		records = append(records, anonymized)
+	}
+
+	if err := writeOutput(opts.OutFile, func(output io.Writer) error {
+		return writeMatchedText(output, records, "")
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.OutFile != "" {
+		fmt.Fprintf(os.Stderr, "[+] %d anonymized accounts written to: %s\n", len(entries), opts.OutFile)
+	}
+}
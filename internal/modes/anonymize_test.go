@@ -0,0 +1,96 @@
+package modes
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fisher0x/hashtocrack/internal/anonymize"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+)
+
+var anonymizeRecords = []*ntds.CrackedEntry{
+	{Entry: ntds.Entry{Username: `CORP\jdoe`, RID: "1101", NTHash: "8846f7eaee8fb117ad06bdd830b7586c"}, Password: "password", Cracked: true},
+	{Entry: ntds.Entry{Username: `CORP\jdoe_adm`, RID: "1102", NTHash: "8846f7eaee8fb117ad06bdd830b7586c"}, Password: "password", Cracked: true},
+	{Entry: ntds.Entry{Username: `CORP\asmith`, RID: "1103", NTHash: "0e2710adc0a29ee8e4f1a9ad694570ca"}},
+}
+
+// writeMatchedFile writes records as a matched file with a redaction header
+func writeMatchedFile(t *testing.T, path string, records []*ntds.CrackedEntry, redaction string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeMatchedText(&buf, records, redaction); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunAnonymize(t *testing.T) {
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "matched.txt"), filepath.Join(dir, "anon.txt")
+	writeMatchedFile(t, input, anonymizeRecords, "")
+
+	RunAnonymize(AnonymizeOptions{InputFile: input, OutFile: output, Secret: []byte("secret"), PasswordMode: anonymize.PasswordsMask})
+
+	file, err := seal.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []*ntds.CrackedEntry
+	scanner := ntds.NewMatchedScanner(file)
+	for scanner.Scan() {
+		entries = append(entries, scanner.Entry())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(anonymizeRecords) {
+		t.Fatalf("%d anonymized entries, want %d", len(entries), len(anonymizeRecords))
+	}
+	for i, entry := range entries {
+		original := anonymizeRecords[i]
+		if entry.Username == original.Username || entry.NTHash == original.NTHash || entry.Cracked != original.Cracked {
+			t.Errorf("entry %d not anonymized: %+v", i, entry)
+		}
+		if original.Cracked && (entry.Password == original.Password || len(entry.Password) != len(original.Password)) {
+			t.Errorf("entry %d: password token %q for %q", i, entry.Password, original.Password)
+		}
+	}
+	// Equal hashes stay equal, and the admin keeps its pair naming
+	if entries[0].NTHash != entries[1].NTHash || !strings.HasSuffix(entries[1].Username, "_adm") {
+		t.Errorf("pair lost: %s %s, %s %s", entries[0].Username, entries[0].NTHash, entries[1].Username, entries[1].NTHash)
+	}
+}
+
+// TestRunAnonymizeRedacted runs RunAnonymize in a subprocess, as refusing
+// the input exits
+func TestRunAnonymizeRedacted(t *testing.T) {
+	if input := os.Getenv("ANONYMIZE_INPUT"); input != "" {
+		RunAnonymize(AnonymizeOptions{InputFile: input, OutFile: os.Getenv("ANONYMIZE_OUTPUT"), Secret: []byte("secret"), PasswordMode: anonymize.PasswordsMask})
+		return
+	}
+
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "matched.txt"), filepath.Join(dir, "anon.txt")
+	writeMatchedFile(t, input, anonymizeRecords, "mask")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunAnonymizeRedacted$")
+	cmd.Env = append(os.Environ(), "ANONYMIZE_INPUT="+input, "ANONYMIZE_OUTPUT="+output)
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); !ok {
+		t.Fatalf("anonymize of a redacted file did not fail: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "redacted by match (mask)") {
+		t.Errorf("unexpected error:\n%s", out)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output written for a redacted file: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package modes
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fisher0x/hashtocrack/internal/charts"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+)
+
+// reportChart is a chart and the base name of the files it is written to
+type reportChart struct {
+	name  string
+	chart charts.Chart
+}
+
+// chartLabel shows empty values explicitly
+func chartLabel(label string) string {
+	if label == "" {
+		return "<empty>"
+	}
+	return label
+}
+
+// reportCharts builds the charts for the analytics report sections
+func reportCharts(result *ntds.AnalyticsResult, opts AnalyticsOptions) []reportChart {
+	var lengths []int
+	for length := range result.LengthDistribution {
+		lengths = append(lengths, length)
+	}
+	sort.Ints(lengths)
+	var lengthItems []charts.Item
+	for _, length := range lengths {
+		lengthItems = append(lengthItems, charts.Item{Label: fmt.Sprint(length), Value: result.LengthDistribution[length]})
+	}
+
+	var passwordItems []charts.Item
+	for _, pwd := range result.TopPasswords {
+		passwordItems = append(passwordItems, charts.Item{Label: chartLabel(pwd.Password), Value: pwd.Count})
+	}
+	if s := result.Suppressed; s.Accounts > 0 {
+		passwordItems = append(passwordItems, charts.Item{Label: fmt.Sprintf("Other (< %d accounts)", s.MinShare), Value: s.Accounts})
+	}
+
+	var maskItems []charts.Item
+	for _, mask := range result.TopMasks {
+		maskItems = append(maskItems, charts.Item{Label: chartLabel(mask.Mask), Value: mask.Count})
+	}
+	if s := result.Suppressed; s.MaskAccounts > 0 {
+		maskItems = append(maskItems, charts.Item{Label: fmt.Sprintf("Other (< %d accounts)", s.MinShare), Value: s.MaskAccounts})
+	}
+
+	list := []reportChart{
+		{"length-distribution", &charts.ColumnChart{Title: "Password Length Distribution", Items: lengthItems, Color: charts.Blue}},
+		{"top-passwords", &charts.BarChart{Title: "Top 10 Most Used Passwords", Items: passwordItems, Color: charts.Purple}},
+		{"top-masks", &charts.BarChart{Title: "Top 10 Password Masks", Items: maskItems, Color: charts.Amber}},
+	}
+	if opts.ShowPasspol {
+		list = append(list, reportChart{"compliance", &charts.DonutChart{
+			Title: "Password Policy Compliance",
+			Items: []charts.Item{
+				{Label: "Compliant", Value: result.ComplexCount},
+				{Label: "Non-compliant", Value: result.CrackedAccounts - result.ComplexCount},
+			},
+			Colors: []color.RGBA{charts.Green, charts.Red},
+		}})
+	}
+	return list
+}
+
+// writeCharts writes every report chart as SVG and PNG into a directory,
+// returning the number of files written
+func writeCharts(dir string, result *ntds.AnalyticsResult, opts AnalyticsOptions) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for _, rc := range reportCharts(result, opts) {
+		for _, out := range []struct {
+			ext   string
+			write func(w io.Writer) error
+		}{
+			{".svg", func(w io.Writer) error { return charts.WriteSVG(w, rc.chart) }},
+			{".png", func(w io.Writer) error { return charts.WritePNG(w, rc.chart) }},
+		} {
+			filename := filepath.Join(dir, rc.name+out.ext)
+			f, err := seal.Create(filename)
+			if err != nil {
+				return written, err
+			}
+			if err := f.Finish(out.write(f)); err != nil {
+				return written, fmt.Errorf("%s: %v", filename, err)
+			}
+			if err := opts.Manifest.AddOutput("chart", filename); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+	return written, nil
+}
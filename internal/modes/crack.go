@@ -0,0 +1,236 @@
+package modes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fisher0x/hashtocrack/internal/hashcat"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// CrackOptions holds the settings for the crack command
+type CrackOptions struct {
+	Plan *hashcat.Plan
+	// Hashcat is the hashcat executable
+	Hashcat string
+	// HashFile keeps the extracted hash list; a temporary file is used
+	// when empty
+	HashFile string
+	// LogFile receives the JSON log of the stages when set
+	LogFile string
+	// Match reads the NTDS file and potfile and writes the matched output
+	// after every stage
+	Match MatchOptions
+}
+
+// StageResult records the run of one stage
+type StageResult struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Command   []string  `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+	Seconds   float64   `json:"seconds"`
+	ExitCode  int       `json:"exit_code"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	// Cracked is the number of cracked accounts after the stage
+	Cracked      int `json:"cracked"`
+	NewlyCracked int `json:"newly_cracked"`
+}
+
+// CrackLog is the log of a crack run
+type CrackLog struct {
+	Hashcat  string        `json:"hashcat"`
+	Potfile  string        `json:"potfile"`
+	Accounts int           `json:"accounts"`
+	Initial  int           `json:"initially_cracked"`
+	Stages   []StageResult `json:"stages"`
+}
+
+// RunCrack extracts the NT hashes, runs the stages of the attack plan with
+// hashcat, and matches the potfile after every stage
+func RunCrack(opts CrackOptions) {
+	hashFile := opts.HashFile
+	if hashFile == "" {
+		tmp, err := os.CreateTemp("", "hashtocrack-*.hashes")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating hash file: %v\n", err)
+			os.Exit(1)
+		}
+		tmp.Close()
+		hashFile = tmp.Name()
+		defer os.Remove(hashFile)
+	}
+	RunExtract(ExtractOptions{
+		NTDSFile:        opts.Match.NTDSFile,
+		OutFile:         hashFile,
+		IncludeDisabled: opts.Match.IncludeDisabled,
+		IncludeMachines: opts.Match.IncludeMachines,
+		Format:          FormatText,
+	})
+
+	// hashcat creates the potfile on the first crack; match needs it now
+	appendPotfile(opts.Match.CrackFile).Close()
+
+	// BloodHound files are written once, for the final results
+	stageMatch := opts.Match
+	stageMatch.BloodHoundCypher, stageMatch.BloodHoundJSON = "", ""
+
+	results := writeMatch(stageMatch)
+	log := &CrackLog{
+		Hashcat:  opts.Hashcat,
+		Potfile:  opts.Match.CrackFile,
+		Accounts: len(results),
+		Initial:  countCracked(results),
+		Stages:   []StageResult{},
+	}
+	cracked := log.Initial
+	fmt.Fprintf(os.Stderr, "[+] %d/%d accounts cracked before the plan\n", cracked, log.Accounts)
+
+	// Interrupts stop the running stage and the plan, but the results so
+	// far are still written
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	interrupted := false
+
+	stages := opts.Plan.Stages
+	for i, stage := range stages {
+		args := opts.Plan.StageArgs(stage, hashFile, opts.Match.CrackFile)
+		fmt.Fprintf(os.Stderr, "[+] Stage %d/%d: %s\n    %s %s\n", i+1, len(stages), stage.Name, opts.Hashcat, strings.Join(args, " "))
+
+		result := StageResult{
+			Name:      stage.Name,
+			Type:      stage.Type,
+			Command:   append([]string{opts.Hashcat}, args...),
+			StartedAt: time.Now().UTC(),
+		}
+		var runErr error
+		result.ExitCode, interrupted, runErr = runHashcat(opts.Hashcat, args, signals)
+		result.Seconds = time.Since(result.StartedAt).Round(100 * time.Millisecond).Seconds()
+		result.Status = hashcat.Status(result.ExitCode)
+		if runErr != nil {
+			result.Error = runErr.Error()
+		}
+
+		results = writeMatch(stageMatch)
+		result.Cracked = countCracked(results)
+		result.NewlyCracked = result.Cracked - cracked
+		cracked = result.Cracked
+		log.Stages = append(log.Stages, result)
+
+		fmt.Fprintf(os.Stderr, "[+] Stage %d/%d %s: %s (exit %d) in %s, %d/%d accounts cracked (+%d)\n",
+			i+1, len(stages), stage.Name, result.Status, result.ExitCode,
+			time.Duration(result.Seconds*float64(time.Second)), cracked, log.Accounts, result.NewlyCracked)
+
+		if interrupted {
+			fmt.Fprintf(os.Stderr, "[!] Interrupted, skipping the remaining stages\n")
+			break
+		}
+		if !hashcat.Completed(result.ExitCode) {
+			if runErr != nil {
+				fmt.Fprintf(os.Stderr, "[!] Stopping the plan: %v\n", runErr)
+			} else {
+				fmt.Fprintf(os.Stderr, "[!] Stopping the plan: hashcat %s (exit %d)\n", result.Status, result.ExitCode)
+			}
+			break
+		}
+	}
+
+	printCrackSummary(log)
+
+	if opts.Match.BloodHoundCypher != "" || opts.Match.BloodHoundJSON != "" {
+		exportBloodHound(opts.Match, results)
+	}
+	recordOutput(opts.Match.Manifest, "potfile", opts.Match.CrackFile)
+	if opts.HashFile != "" {
+		recordOutput(opts.Match.Manifest, "hashes", opts.HashFile)
+	}
+	if opts.LogFile != "" {
+		writeCrackLog(opts.LogFile, log)
+		recordOutput(opts.Match.Manifest, "crack-log", opts.LogFile)
+		fmt.Fprintf(os.Stderr, "[+] Crack log written to: %s\n", opts.LogFile)
+	}
+}
+
+// runHashcat runs hashcat on the terminal and returns its exit code. A
+// signal received meanwhile is passed on to hashcat and reported as an
+// interrupt. The exit code is -1 when hashcat could not be started.
+func runHashcat(binary string, args []string, signals <-chan os.Signal) (int, bool, error) {
+	cmd := exec.Command(binary, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return -1, false, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	interrupted := false
+	for {
+		select {
+		case sig := <-signals:
+			interrupted = true
+			cmd.Process.Signal(sig)
+		case err := <-done:
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				return -1, interrupted, err
+			}
+			return cmd.ProcessState.ExitCode(), interrupted, nil
+		}
+	}
+}
+
+// countCracked returns the number of cracked accounts
+func countCracked(results []*ntds.CrackedEntry) int {
+	count := 0
+	for _, result := range results {
+		if result.Cracked {
+			count++
+		}
+	}
+	return count
+}
+
+// printCrackSummary prints the exit status, time and cracks of every stage
+func printCrackSummary(log *CrackLog) {
+	fmt.Fprintf(os.Stderr, "\nAttack plan summary:\n")
+	fmt.Fprintf(os.Stderr, "  %-3s %-28s %-22s %10s %8s\n", "#", "Stage", "Status", "Time", "Cracked")
+	for i, stage := range log.Stages {
+		fmt.Fprintf(os.Stderr, "  %-3d %-28s %-22s %10s %8s\n", i+1, utils.Truncate(stage.Name, 28),
+			fmt.Sprintf("%s (%d)", stage.Status, stage.ExitCode),
+			time.Duration(stage.Seconds*float64(time.Second)).Round(time.Second),
+			fmt.Sprintf("+%d", stage.NewlyCracked))
+	}
+	cracked := log.Initial
+	if len(log.Stages) > 0 {
+		cracked = log.Stages[len(log.Stages)-1].Cracked
+	}
+	percent := 0.0
+	if log.Accounts > 0 {
+		percent = float64(cracked) / float64(log.Accounts) * 100
+	}
+	fmt.Fprintf(os.Stderr, "  %d/%d accounts cracked (%.1f%%), %d during the plan\n\n", cracked, log.Accounts, percent, cracked-log.Initial)
+}
+
+// writeCrackLog writes the stage log as indented JSON
+func writeCrackLog(filename string, log *CrackLog) {
+	output := createOutput(filename)
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err == nil {
+		_, err = output.Write(append(data, '\n'))
+	}
+	if err = output.Finish(err); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing crack log: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,140 @@
+package modes
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/fisher0x/hashtocrack/internal/hashcat"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+)
+
+// stubHashcat records its arguments to $STUB_LOG, appends the --stub-crack
+// lines to the potfile and exits with --stub-exit (default 1, exhausted)
+const stubHashcat = `#!/bin/sh
+echo "$*" >> "$STUB_LOG"
+potfile= code=1
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--potfile-path) potfile=$2; shift ;;
+	--stub-crack) echo "$2" >> "$potfile"; shift ;;
+	--stub-exit) code=$2; shift ;;
+	esac
+	shift
+done
+exit $code
+`
+
+func TestRunCrack(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub hashcat is a shell script")
+	}
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ntdsLines := []string{
+		`CORP\jdoe:1101:aad3b435b51404eeaad3b435b51404ee:8846f7eaee8fb117ad06bdd830b7586c::: (status=Enabled)`,
+		`CORP\jdoe_adm:1102:aad3b435b51404eeaad3b435b51404ee:8846f7eaee8fb117ad06bdd830b7586c::: (status=Enabled)`,
+		`CORP\asmith:1103:aad3b435b51404eeaad3b435b51404ee:31d6cfe0d16ae931b73c59d7e0c089c0::: (status=Enabled)`,
+		`CORP\bwayne:1104:aad3b435b51404eeaad3b435b51404ee:0e2710adc0a29ee8e4f1a9ad694570ca::: (status=Enabled)`,
+	}
+	files := map[string]string{
+		"ntds.txt":    strings.Join(ntdsLines, "\n") + "\n",
+		"cracked.pot": "31d6cfe0d16ae931b73c59d7e0c089c0:\n",
+		"hashcat":     stubHashcat,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path(name), []byte(content), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("STUB_LOG", path("calls.txt"))
+
+	plan := &hashcat.Plan{
+		Args: []string{"-O"},
+		Stages: []hashcat.Stage{
+			{Name: "words", Type: hashcat.StageWordlist, Wordlists: []string{"words.txt"},
+				Args: []string{"--stub-crack", "8846f7eaee8fb117ad06bdd830b7586c:password", "--stub-exit", "0"}},
+			{Name: "broken", Type: hashcat.StageMask, Mask: "?d", Args: []string{"--stub-exit", "255"}},
+			{Name: "never", Type: hashcat.StageMask, Mask: "?d?d"},
+		},
+	}
+	RunCrack(CrackOptions{
+		Plan:     plan,
+		Hashcat:  path("hashcat"),
+		HashFile: path("hashes.txt"),
+		LogFile:  path("crack.json"),
+		Match: MatchOptions{
+			NTDSFile:  path("ntds.txt"),
+			CrackFile: path("cracked.pot"),
+			OutFile:   path("matched.txt"),
+			Format:    FormatText,
+		},
+	})
+
+	// hashcat runs the first two stages with the plan's arguments; the
+	// failed stage stops the plan
+	calls, err := os.ReadFile(path("calls.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, stage := range plan.Stages[:2] {
+		want = append(want, strings.Join(plan.StageArgs(stage, path("hashes.txt"), path("cracked.pot")), " "))
+	}
+	if got := strings.TrimSpace(string(calls)); got != strings.Join(want, "\n") {
+		t.Errorf("hashcat calls:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	hashes, _ := os.ReadFile(path("hashes.txt"))
+	if strings.Count(string(hashes), "\n") != len(ntdsLines) {
+		t.Errorf("hash file:\n%s", hashes)
+	}
+
+	data, err := os.ReadFile(path("crack.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log CrackLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Accounts != 4 || log.Initial != 1 || len(log.Stages) != 2 {
+		t.Fatalf("log: %d accounts, %d initially cracked, %d stages", log.Accounts, log.Initial, len(log.Stages))
+	}
+	stages := []struct {
+		status         string
+		exit           int
+		cracked, newly int
+	}{
+		{"cracked", 0, 3, 2},
+		{"error", 255, 3, 0},
+	}
+	for i, want := range stages {
+		got := log.Stages[i]
+		if got.Status != want.status || got.ExitCode != want.exit || got.Cracked != want.cracked || got.NewlyCracked != want.newly {
+			t.Errorf("stage %d: %s (exit %d), %d cracked (+%d), want %s (exit %d), %d cracked (+%d)",
+				i+1, got.Status, got.ExitCode, got.Cracked, got.NewlyCracked, want.status, want.exit, want.cracked, want.newly)
+		}
+	}
+
+	file, err := seal.Open(path("matched.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	passwords := make(map[string]string)
+	scanner := ntds.NewMatchedScanner(file)
+	for scanner.Scan() {
+		if entry := scanner.Entry(); entry.Cracked {
+			passwords[entry.Username] = entry.Password
+		}
+	}
+	if len(passwords) != 3 || passwords[`CORP\jdoe_adm`] != "password" || passwords[`CORP\asmith`] != "" {
+		t.Errorf("matched passwords: %q", passwords)
+	}
+}
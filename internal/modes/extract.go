@@ -3,61 +3,105 @@ package modes
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
 
+	"github.com/fisher0x/hashtocrack/internal/manifest"
 	"github.com/fisher0x/hashtocrack/internal/ntds"
-	"github.com/fisher0x/hashtocrack/internal/utils"
+	"github.com/fisher0x/hashtocrack/internal/seal"
 )
 
+// Hash list formats of extract mode, besides text (one NT hash per account)
+const (
+	// FormatUnique lists every NT hash once, the most shared first
+	FormatUnique = "unique"
+	// FormatUsername writes user:hash lines for hashcat --username
+	FormatUsername = "username"
+	// FormatJohn writes user:$NT$hash lines for John the Ripper
+	FormatJohn = "john"
+	// FormatPwdump writes full user:rid:lm:nt::: lines
+	FormatPwdump = "pwdump"
+	// FormatLMNT writes the LM:NT pairs taken by impacket's -hashes
+	FormatLMNT = "lmnt"
+)
+
+// ExtractOptions holds the settings for extract mode
+type ExtractOptions struct {
+	NTDSFile        string
+	OutFile         string
+	IncludeDisabled bool
+	IncludeMachines bool
+	Format          string
+	// ExcludePotfiles drop the accounts whose NT hash is cracked in any of
+	// these potfiles
+	ExcludePotfiles []string
+	// Manifest records the run's counts when set
+	Manifest *manifest.Manifest
+}
+
 // RunExtract extracts hashes from NTDS file
 // This is equivalent to: grep -iv disabled ntdsfile | cut -d ':' -f4
 // Or with -disabled flag: cat ntdsfile | cut -d ':' -f4
-func RunExtract(ntdsFile, outfile string, includeDisabled, includeMachines bool) {
-	file, err := os.Open(ntdsFile)
+func RunExtract(opts ExtractOptions) {
+	file, err := seal.Open(opts.NTDSFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
 		os.Exit(1)
 	}
 	defer file.Close()
 
-	var output *os.File
-	if outfile != "" {
-		if err := utils.EnsureDir(outfile); err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
-			os.Exit(1)
-		}
-		output, err = os.Create(outfile)
+	cracked := make(map[string]string)
+	for _, filename := range opts.ExcludePotfiles {
+		potfile, err := ntds.LoadPotfile(filename)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error loading potfile: %v\n", err)
 			os.Exit(1)
 		}
-		defer output.Close()
+		for hash, password := range potfile {
+			cracked[hash] = password
+		}
 	}
 
+	var entries []*ntds.Entry
+	processed, skipped := 0, 0
+	// remaining counts the accounts of each hash not in the potfiles
+	remaining := make(map[string]int)
+	// excluded counts the accounts of each NTDS hash found in the potfiles
+	excluded := make(map[string]int)
+
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		entry, err := ntds.ParseLine(scanner.Text())
 		if err != nil {
+			if strings.TrimSpace(scanner.Text()) != "" {
+				skipped++
+			}
 			continue
 		}
 
 		// Skip disabled unless flag is set
-		if entry.IsDisabled && !includeDisabled {
+		if entry.IsDisabled && !opts.IncludeDisabled {
+			skipped++
 			continue
 		}
 
 		// Skip machine accounts unless flag is set
-		if entry.IsMachine && !includeMachines {
+		if entry.IsMachine && !opts.IncludeMachines {
+			skipped++
 			continue
 		}
 
-		line := entry.NTHash
-
-		if output != nil {
-			fmt.Fprintln(output, line)
-		} else {
-			fmt.Println(line)
+		// Skip hashes already cracked when potfiles are given
+		hash := strings.ToLower(entry.NTHash)
+		if _, ok := cracked[hash]; ok {
+			excluded[hash]++
+			continue
 		}
+		remaining[hash]++
+		processed++
+		entries = append(entries, entry)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -65,7 +109,89 @@ func RunExtract(ntdsFile, outfile string, includeDisabled, includeMachines bool)
 		os.Exit(1)
 	}
 
-	if outfile != "" {
-		fmt.Fprintf(os.Stderr, "[+] Hashes written to: %s\n", outfile)
+	excludedAccounts := 0
+	for _, count := range excluded {
+		excludedAccounts += count
+	}
+	opts.Manifest.SetCounts(processed, skipped)
+	opts.Manifest.SetExcluded(excludedAccounts)
+
+	err = writeOutput(opts.OutFile, func(output io.Writer) error {
+		if opts.Format == FormatJSON || opts.Format == FormatJSONL {
+			return writeRecords(output, opts.Format, entries)
+		}
+		return writeHashList(output, opts.Format, entries)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+	if len(opts.ExcludePotfiles) > 0 {
+		fmt.Fprintf(os.Stderr, "[+] %d unique NT hashes remaining for %d accounts (%d accounts excluded: %d hashes cracked in the potfiles)\n",
+			len(remaining), processed, excludedAccounts, len(excluded))
+	} else if opts.Format == FormatUnique {
+		fmt.Fprintf(os.Stderr, "[+] %d unique NT hashes for %d accounts\n", len(remaining), processed)
+	}
+
+	if opts.OutFile != "" {
+		fmt.Fprintf(os.Stderr, "[+] Hashes written to: %s\n", opts.OutFile)
+	}
+}
+
+// hashCount is an NT hash and the number of accounts using it
+type hashCount struct {
+	Hash     string
+	Accounts int
+}
+
+// uniqueHashes returns the lowercased NT hashes of the entries, the most
+// shared first, then in order of appearance
+func uniqueHashes(entries []*ntds.Entry) []hashCount {
+	index := make(map[string]int)
+	var hashes []hashCount
+	for _, entry := range entries {
+		hash := strings.ToLower(entry.NTHash)
+		if i, ok := index[hash]; ok {
+			hashes[i].Accounts++
+			continue
+		}
+		index[hash] = len(hashes)
+		hashes = append(hashes, hashCount{hash, 1})
+	}
+	sort.SliceStable(hashes, func(i, j int) bool {
+		return hashes[i].Accounts > hashes[j].Accounts
+	})
+	return hashes
+}
+
+// writeHashList writes the entries in text format or one of the hash list
+// formats
+func writeHashList(w io.Writer, format string, entries []*ntds.Entry) error {
+	bw := bufio.NewWriter(w)
+	if format == FormatUnique {
+		for _, hash := range uniqueHashes(entries) {
+			fmt.Fprintln(bw, hash.Hash)
+		}
+		return bw.Flush()
+	}
+
+	for _, entry := range entries {
+		lm := entry.LMHash
+		if lm == "" {
+			lm = ntds.EmptyLMHash
+		}
+		switch format {
+		case FormatText:
+			fmt.Fprintln(bw, entry.NTHash)
+		case FormatUsername:
+			fmt.Fprintf(bw, "%s:%s\n", entry.Username, entry.NTHash)
+		case FormatJohn:
+			fmt.Fprintf(bw, "%s:$NT$%s\n", entry.Username, entry.NTHash)
+		case FormatPwdump:
+			fmt.Fprintf(bw, "%s:%s:%s:%s:::\n", entry.Username, entry.RID, lm, entry.NTHash)
+		case FormatLMNT:
+			fmt.Fprintf(bw, "%s:%s\n", lm, entry.NTHash)
+		}
 	}
+	return bw.Flush()
 }
@@ -0,0 +1,26 @@
+package modes
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PasswordMask returns the hashcat mask of a password: ?u upper, ?l lower,
+// ?d digit and ?s anything else, using the same character categories as the
+// complexity check
+func PasswordMask(password string) string {
+	var b strings.Builder
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			b.WriteString("?u")
+		case unicode.IsLower(char):
+			b.WriteString("?l")
+		case unicode.IsDigit(char):
+			b.WriteString("?d")
+		default:
+			b.WriteString("?s")
+		}
+	}
+	return b.String()
+}
@@ -3,93 +3,228 @@ package modes
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/fisher0x/hashtocrack/internal/bloodhound"
+	"github.com/fisher0x/hashtocrack/internal/manifest"
 	"github.com/fisher0x/hashtocrack/internal/ntds"
-	"github.com/fisher0x/hashtocrack/internal/utils"
+	"github.com/fisher0x/hashtocrack/internal/redact"
+	"github.com/fisher0x/hashtocrack/internal/seal"
 )
 
+// MatchOptions holds the settings for match mode
+type MatchOptions struct {
+	NTDSFile        string
+	CrackFile       string
+	OutFile         string
+	IncludeDisabled bool
+	IncludeMachines bool
+	Format          string
+	// Redactor redacts the passwords written to every output when set
+	Redactor *redact.Redactor
+	// Manifest records the run's counts and BloodHound files when set
+	Manifest *manifest.Manifest
+
+	// BloodHound export
+	BloodHoundCypher string
+	BloodHoundJSON   string
+	Domains          map[string]string
+	DomainSIDs       map[string]string
+
+	// Watch follows the potfile and appends newly cracked accounts to the
+	// output until interrupted
+	Watch         bool
+	WatchInterval time.Duration
+	// GroupFiles and ExtraPrivileged tag privileged accounts in the watch
+	// status
+	GroupFiles      []string
+	ExtraPrivileged []string
+}
+
 // RunMatch matches NTDS entries with cracked passwords from a potfile
-// Output format: username:hash:password:status
-func RunMatch(ntdsFile, crackFile, outfile string, includeDisabled, includeMachines bool) {
+// Output format: versioned matched file (see ntds.MatchedColumns)
+func RunMatch(opts MatchOptions) {
+	if opts.Watch {
+		watchMatch(opts)
+		return
+	}
+	writeMatch(opts)
+}
+
+// writeMatch matches the accounts and writes the output and BloodHound files,
+// returning the match results
+func writeMatch(opts MatchOptions) []*ntds.CrackedEntry {
 	// Load potfile
-	potfile, err := ntds.LoadPotfile(crackFile)
+	potfile, err := ntds.LoadPotfile(opts.CrackFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading potfile: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Open NTDS file
-	file, err := os.Open(ntdsFile)
+	// results keep the plaintext passwords for the BloodHound export
+	results, skipped, err := matchEntries(opts, potfile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening NTDS file: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
 		os.Exit(1)
 	}
-	defer file.Close()
+	opts.Manifest.SetCounts(len(results), skipped)
 
-	var output *os.File
-	if outfile != "" {
-		if err := utils.EnsureDir(outfile); err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
-			os.Exit(1)
-		}
-		output, err = os.Create(outfile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
-			os.Exit(1)
+	// records are the possibly redacted entries written to the output
+	records := make([]*ntds.CrackedEntry, len(results))
+	for i, result := range results {
+		records[i] = redactRecord(result, opts.Redactor)
+	}
+
+	err = writeOutput(opts.OutFile, func(output io.Writer) error {
+		switch opts.Format {
+		case FormatText:
+			return writeMatchedText(output, records, redaction(opts.Redactor))
+		case FormatXLSX:
+			return writeXLSXRecords(output, results, opts.Redactor)
 		}
-		defer output.Close()
+		return writeRecords(output, opts.Format, records)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.OutFile != "" {
+		fmt.Fprintf(os.Stderr, "[+] Matched results written to: %s\n", opts.OutFile)
+	}
+
+	if opts.BloodHoundCypher != "" || opts.BloodHoundJSON != "" {
+		exportBloodHound(opts, results)
+	}
+	return results
+}
+
+// matchEntries reads the NTDS file and matches the accounts kept by the
+// filters with the potfile. It also returns the number of skipped accounts
+// and unparseable lines.
+func matchEntries(opts MatchOptions, potfile map[string]string) ([]*ntds.CrackedEntry, int, error) {
+	file, err := seal.Open(opts.NTDSFile)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening NTDS file: %v", err)
 	}
+	defer file.Close()
+
+	var results []*ntds.CrackedEntry
+	skipped := 0
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		entry, err := ntds.ParseLine(scanner.Text())
 		if err != nil {
+			if strings.TrimSpace(scanner.Text()) != "" {
+				skipped++
+			}
 			continue
 		}
 
 		// Skip disabled unless flag is set
-		if entry.IsDisabled && !includeDisabled {
+		if entry.IsDisabled && !opts.IncludeDisabled {
+			skipped++
 			continue
 		}
 
 		// Skip machine accounts unless flag is set
-		if entry.IsMachine && !includeMachines {
+		if entry.IsMachine && !opts.IncludeMachines {
+			skipped++
 			continue
 		}
 
-		status := "Enabled"
-		if entry.IsDisabled {
-			status = "Disabled"
-		}
+		// Check if hash is cracked (an empty password is a valid crack)
+		password, found := potfile[strings.ToLower(entry.NTHash)]
+		results = append(results, &ntds.CrackedEntry{Entry: *entry, Password: password, Cracked: found})
+	}
 
-		// Check if hash is cracked
-		password := ""
-		if pwd, found := potfile[strings.ToLower(entry.NTHash)]; found {
-			password = pwd
-		}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("reading file: %v", err)
+	}
+	return results, skipped, nil
+}
 
-		var line string
-		if password != "" {
-			line = fmt.Sprintf("%s:%s:%s:%s", entry.Username, entry.NTHash, password, status)
-		} else {
-			line = fmt.Sprintf("%s:%s::%s", entry.Username, entry.NTHash, status)
-		}
+// redactRecord returns the entry as written to the output: a copy with the
+// password redacted when a redactor is set
+func redactRecord(result *ntds.CrackedEntry, redactor *redact.Redactor) *ntds.CrackedEntry {
+	if redactor == nil {
+		return result
+	}
+	redacted := *result
+	redacted.Password = redactor.Redact(result.Password)
+	return &redacted
+}
 
-		if output != nil {
-			fmt.Fprintln(output, line)
-		} else {
-			fmt.Println(line)
+// redaction describes the redactor for the matched file header, "" when
+// the passwords are not redacted
+func redaction(redactor *redact.Redactor) string {
+	if redactor == nil {
+		return ""
+	}
+	return redactor.Description()
+}
+
+// writeMatchedText writes the header and records of a matched file,
+// recording the redaction strategy of the passwords in the header
+func writeMatchedText(w io.Writer, records []*ntds.CrackedEntry, redaction string) error {
+	if err := ntds.WriteMatchedHeader(w, redaction); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, err := fmt.Fprintln(w, ntds.FormatMatchedRecord(record)); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-		os.Exit(1)
+// exportBloodHound writes the BloodHound owned-marking files for the match results
+func exportBloodHound(opts MatchOptions, results []*ntds.CrackedEntry) {
+	redactor := opts.Redactor
+	if redactor == nil {
+		redactor = redact.Default()
+	}
+	exporter := &bloodhound.Exporter{
+		Domains:    opts.Domains,
+		DomainSIDs: opts.DomainSIDs,
+		Redact:     redactor.Redact,
+	}
+
+	for _, domain := range exporter.UnmappedDomains(results) {
+		fmt.Fprintf(os.Stderr, "[!] No FQDN mapping for domain '%s', using it as-is (see -bh-domains)\n", domain)
+	}
+	if count := bloodhound.Domainless(results); count > 0 {
+		fmt.Fprintf(os.Stderr, "[!] %d cracked users without a domain skipped in the BloodHound files: no node name to match\n", count)
 	}
 
-	if outfile != "" {
-		fmt.Fprintf(os.Stderr, "[+] Matched results written to: %s\n", outfile)
+	if opts.BloodHoundCypher != "" {
+		output := createOutput(opts.BloodHoundCypher)
+		count, err := exporter.WriteCypher(output, results)
+		err = output.Finish(err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing Cypher script: %v\n", err)
+			os.Exit(1)
+		}
+		recordOutput(opts.Manifest, "bloodhound-cypher", opts.BloodHoundCypher)
+		fmt.Fprintf(os.Stderr, "[+] BloodHound Cypher script (%d owned users) written to: %s\n", count, opts.BloodHoundCypher)
+	}
+
+	if opts.BloodHoundJSON != "" {
+		output := createOutput(opts.BloodHoundJSON)
+		count, skipped, err := exporter.WriteIngest(output, results)
+		err = output.Finish(err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing BloodHound ingest file: %v\n", err)
+			os.Exit(1)
+		}
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "[!] %d cracked users skipped in ingest file: unknown domain SID (see -bh-sids)\n", skipped)
+		}
+		recordOutput(opts.Manifest, "bloodhound-ingest", opts.BloodHoundJSON)
+		fmt.Fprintf(os.Stderr, "[+] BloodHound ingest file (%d owned users) written to: %s\n", count, opts.BloodHoundJSON)
 	}
 }
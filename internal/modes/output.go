@@ -0,0 +1,116 @@
+package modes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fisher0x/hashtocrack/internal/manifest"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// Output formats
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+	FormatHTML  = "html"
+
+	FormatMarkdown = "markdown"
+	FormatAsciiDoc = "asciidoc"
+
+	FormatXLSX = "xlsx"
+)
+
+// createOutput creates an output file and its directory, exiting on failure.
+// The file is private to the owner, encrypted when a key is configured, and
+// only replaces the target once closed.
+func createOutput(filename string) *seal.File {
+	if err := utils.EnsureDir(filename); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+		os.Exit(1)
+	}
+	output, err := seal.Create(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+		os.Exit(1)
+	}
+	return output
+}
+
+// writeOutput writes a mode's output with write: to the given file, or to
+// stdout when no file is set. The file only replaces the target once write
+// succeeds; on failure it is discarded and the error returned.
+func writeOutput(outfile string, write func(w io.Writer) error) error {
+	if outfile == "" {
+		return write(os.Stdout)
+	}
+	if err := utils.EnsureDir(outfile); err != nil {
+		return fmt.Errorf("creating output directory: %v", err)
+	}
+	output, err := seal.Create(outfile)
+	if err != nil {
+		return fmt.Errorf("creating output file: %v", err)
+	}
+	return output.Finish(write(output))
+}
+
+// appendPotfile opens a potfile for appending, creating it if missing, and
+// exits if it is encrypted: hashcat and the potfile readers need plaintext
+// lines.
+func appendPotfile(filename string) *os.File {
+	if err := utils.EnsureDir(filename); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating potfile directory: %v\n", err)
+		os.Exit(1)
+	}
+	potfile, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening potfile: %v\n", err)
+		os.Exit(1)
+	}
+	magic := make([]byte, len(seal.Magic))
+	n, _ := io.ReadFull(potfile, magic)
+	if seal.IsSealed(magic[:n]) {
+		potfile.Close()
+		fmt.Fprintf(os.Stderr, "Error: %s is encrypted; hashcat needs a plaintext potfile\n", filename)
+		os.Exit(1)
+	}
+	return potfile
+}
+
+// recordOutput adds a completed output file to the manifest, exiting on failure
+func recordOutput(m *manifest.Manifest, role, filename string) {
+	if err := m.AddOutput(role, filename); err != nil {
+		fmt.Fprintf(os.Stderr, "Error hashing output file for the manifest: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeJSON writes a single value as indented JSON, or compact on one line for jsonl
+func writeJSON(w io.Writer, format string, value interface{}) error {
+	enc := json.NewEncoder(w)
+	if format != FormatJSONL {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(value)
+}
+
+// writeRecords writes records as a JSON array, or one JSON object per line for jsonl
+func writeRecords[T any](w io.Writer, format string, records []T) error {
+	if format != FormatJSONL {
+		if records == nil {
+			records = []T{}
+		}
+		return writeJSON(w, format, records)
+	}
+
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package modes
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+)
+
+// DefaultPairPatterns are the admin naming conventions checked when none are given.
+// {user} is replaced by the regular account's name.
+var DefaultPairPatterns = []string{
+	"{user}_adm",
+	"{user}-adm",
+	"{user}.adm",
+	"{user}_admin",
+	"{user}-admin",
+	"{user}.admin",
+	"adm_{user}",
+	"adm-{user}",
+	"adm.{user}",
+	"admin_{user}",
+	"admin-{user}",
+	"a-{user}",
+}
+
+// matchPairPattern returns the regular account name that the pattern
+// derives the given admin name from, if any
+func matchPairPattern(pattern, name string) (string, bool) {
+	idx := strings.Index(pattern, "{user}")
+	if idx == -1 {
+		return "", false
+	}
+	prefix := strings.ToLower(pattern[:idx])
+	suffix := strings.ToLower(pattern[idx+len("{user}"):])
+	lower := strings.ToLower(name)
+
+	if len(lower) <= len(prefix)+len(suffix) {
+		return "", false
+	}
+	if !strings.HasPrefix(lower, prefix) || !strings.HasSuffix(lower, suffix) {
+		return "", false
+	}
+	return lower[len(prefix) : len(lower)-len(suffix)], true
+}
+
+// editDistance returns the Levenshtein distance between two strings, rune-wise
+func editDistance(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// FindAccountPairs pairs regular and admin accounts using the naming patterns
+// and returns the pairs sharing an NT hash or having near-identical cracked passwords
+func FindAccountPairs(entries []*ntds.CrackedEntry, patterns []string, maxDistance int) []ntds.AccountPair {
+	if len(patterns) == 0 {
+		patterns = DefaultPairPatterns
+	}
+
+	// Index accounts by lowercase DOMAIN\name
+	byName := make(map[string]*ntds.CrackedEntry)
+	for _, entry := range entries {
+		byName[strings.ToLower(entry.Username)] = entry
+	}
+
+	pairs := []ntds.AccountPair{}
+	seen := make(map[string]bool)
+
+	for _, admin := range entries {
+		domain, name := ntds.SplitUsername(admin.Username)
+
+		for _, pattern := range patterns {
+			base, ok := matchPairPattern(pattern, name)
+			if !ok {
+				continue
+			}
+
+			key := base
+			if domain != "" {
+				key = strings.ToLower(domain) + "\\" + base
+			}
+			user, found := byName[key]
+			if !found || user == admin {
+				continue
+			}
+
+			pairKey := strings.ToLower(user.Username) + "|" + strings.ToLower(admin.Username)
+			if seen[pairKey] {
+				continue
+			}
+			seen[pairKey] = true
+
+			pair := ntds.AccountPair{User: user.Username, Admin: admin.Username, Pattern: pattern}
+			if strings.EqualFold(user.NTHash, admin.NTHash) {
+				pair.Kind = ntds.PairSameHash
+				pair.NTHash = admin.NTHash
+			} else if user.Cracked && admin.Cracked {
+				pair.Distance = editDistance(user.Password, admin.Password)
+				if pair.Distance > maxDistance {
+					continue
+				}
+				pair.Kind = ntds.PairSimilarPassword
+				pair.UserPassword = user.Password
+				pair.AdminPassword = admin.Password
+			} else {
+				continue
+			}
+
+			pairs = append(pairs, pair)
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Kind != pairs[j].Kind {
+			return pairs[i].Kind == ntds.PairSameHash
+		}
+		return strings.ToLower(pairs[i].User) < strings.ToLower(pairs[j].User)
+	})
+
+	return pairs
+}
@@ -0,0 +1,216 @@
+package modes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/manifest"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/ntlm"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// PotmergeOptions holds the settings for the potmerge command
+type PotmergeOptions struct {
+	Potfiles []string
+	OutFile  string
+	// Types keeps the hash types (ntds.PotNT, PotLM, PotOther) when set
+	Types    []string
+	Manifest *manifest.Manifest
+}
+
+// potSource is the contribution of one potfile to the merge
+type potSource struct {
+	file  string
+	lines int
+	// added counts the hashes first seen in this potfile
+	added int
+	// duplicates counts the entries already merged with the same password
+	duplicates int
+	// conflicts counts the entries giving a merged hash another password
+	conflicts int
+	invalid   int
+	filtered  int
+	// kept counts the merged entries whose password comes from this potfile
+	kept int
+}
+
+// potEntry is a merged potfile entry. Other hash types are kept as their
+// raw line, as their hash and password cannot be told apart reliably.
+type potEntry struct {
+	hash     string
+	password string
+	raw      string
+	source   int
+}
+
+// line formats the entry as hashcat writes it
+func (e *potEntry) line() string {
+	if e.raw != "" {
+		return e.raw
+	}
+	return e.hash + ":" + ntds.EncodeHex(e.password)
+}
+
+// RunPotmerge merges potfiles: hashes are lowercased, $HEX[] passwords
+// decoded and re-encoded, and duplicates dropped. A hash cracked to
+// different passwords keeps the one whose NT hash matches.
+func RunPotmerge(opts PotmergeOptions) {
+	keep := make(map[string]bool)
+	for _, t := range opts.Types {
+		keep[t] = true
+	}
+
+	var entries []*potEntry
+	index := make(map[string]*potEntry)
+	sources := make([]potSource, len(opts.Potfiles))
+	resolved, unresolved := 0, 0
+
+	for i, filename := range opts.Potfiles {
+		source := &sources[i]
+		source.file = filename
+		err := readPotLines(filename, func(line string) {
+			source.lines++
+			hash, password, ok := ntds.ParsePotLine(line)
+			if !ok {
+				source.invalid++
+				return
+			}
+			hashType := ntds.PotHashType(hash)
+			if len(keep) > 0 && !keep[hashType] {
+				source.filtered++
+				return
+			}
+
+			entry := &potEntry{hash: hash, password: password, source: i}
+			key := hash
+			if hashType == ntds.PotOther {
+				entry.raw = line
+				key = line
+			}
+
+			merged, exists := index[key]
+			switch {
+			case !exists:
+				index[key] = entry
+				entries = append(entries, entry)
+				source.added++
+			case merged.password == entry.password:
+				source.duplicates++
+			default:
+				source.conflicts++
+				winner, verified := resolveConflict(hashType, merged, entry)
+				if verified {
+					resolved++
+				} else {
+					unresolved++
+				}
+				fmt.Fprintf(os.Stderr, "[!] Conflict on %s between %s and %s: %s\n", hash,
+					filepath.Base(opts.Potfiles[merged.source]), filepath.Base(filename),
+					conflictOutcome(opts.Potfiles[winner.source], verified))
+				*merged = *winner
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading potfile: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	for _, entry := range entries {
+		sources[entry.source].kept++
+	}
+	err := writeOutput(opts.OutFile, func(output io.Writer) error {
+		w := bufio.NewWriter(output)
+		for _, entry := range entries {
+			fmt.Fprintln(w, entry.line())
+		}
+		return w.Flush()
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+
+	skipped := 0
+	for _, source := range sources {
+		skipped += source.invalid + source.filtered
+	}
+	opts.Manifest.SetCounts(len(entries), skipped)
+
+	printPotSources(sources, len(entries), resolved, unresolved)
+	if opts.OutFile != "" {
+		fmt.Fprintf(os.Stderr, "[+] Merged potfile written to: %s\n", opts.OutFile)
+	}
+}
+
+// readPotLines passes the non-blank lines of a potfile to fn, without the
+// line break but otherwise unchanged
+func readPotLines(filename string, fn func(line string)) error {
+	file, err := seal.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) != "" {
+			fn(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+}
+
+// resolveConflict picks the password of a hash cracked twice: the one whose
+// NT hash matches for NT hashes, read as UTF-8 or as hashcat's Latin-1
+// bytes, else the one merged first. verified reports whether the NT hash
+// decided.
+func resolveConflict(hashType string, merged, entry *potEntry) (winner *potEntry, verified bool) {
+	if hashType != ntds.PotNT {
+		return merged, false
+	}
+	mergedOK := ntlm.Verify(merged.password, merged.hash)
+	entryOK := ntlm.Verify(entry.password, entry.hash)
+	switch {
+	case mergedOK && !entryOK:
+		return merged, true
+	case entryOK && !mergedOK:
+		return entry, true
+	}
+	return merged, false
+}
+
+// conflictOutcome describes how a conflict was resolved
+func conflictOutcome(source string, verified bool) string {
+	if verified {
+		return fmt.Sprintf("kept the password from %s (NT hash verified)", filepath.Base(source))
+	}
+	return fmt.Sprintf("kept the password from %s (not verified)", filepath.Base(source))
+}
+
+// printPotSources prints the merge summary and the contribution of every
+// potfile
+func printPotSources(sources []potSource, merged, resolved, unresolved int) {
+	fmt.Fprintf(os.Stderr, "\nPotfile contributions:\n")
+	fmt.Fprintf(os.Stderr, "  %-28s %8s %8s %10s %9s %8s %9s %8s\n",
+		"Potfile", "Lines", "New", "Duplicate", "Conflict", "Invalid", "Filtered", "Kept")
+	for _, s := range sources {
+		fmt.Fprintf(os.Stderr, "  %-28s %8d %8d %10d %9d %8d %9d %8d\n",
+			utils.Truncate(filepath.Base(s.file), 28), s.lines, s.added, s.duplicates, s.conflicts, s.invalid, s.filtered, s.kept)
+	}
+	fmt.Fprintf(os.Stderr, "  %d entries merged from %d potfiles, %d conflicts resolved by NT hash, %d unresolved\n\n",
+		merged, len(sources), resolved, unresolved)
+}
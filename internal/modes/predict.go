@@ -0,0 +1,162 @@
+package modes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/fisher0x/hashtocrack/internal/manifest"
+	"github.com/fisher0x/hashtocrack/internal/predict"
+)
+
+// PredictOptions holds the settings for the predict command
+type PredictOptions struct {
+	InputFile       string
+	OutFile         string
+	IncludeDisabled bool
+	IncludeMachines bool
+	Format          string
+	// Direction is next, previous or both
+	Direction string
+	// Steps is the number of rotations predicted in each direction
+	Steps int
+	// PerAccount writes the predictions of every account instead of a
+	// global wordlist
+	PerAccount bool
+	Manifest   *manifest.Manifest
+}
+
+// AccountPrediction is the per-account record of the predict command
+type AccountPrediction struct {
+	Username string          `json:"username"`
+	Password string          `json:"password"`
+	Guesses  []predict.Guess `json:"guesses"`
+}
+
+// PredictedPassword is the global record of the predict command
+type PredictedPassword struct {
+	Password string `json:"password"`
+	// Accounts is the number of accounts whose password predicts it
+	Accounts int `json:"accounts"`
+	// Step is the closest rotation giving it, positive for next passwords
+	Step int `json:"step"`
+}
+
+// RunPredict predicts the previous and next passwords of the cracked
+// accounts of a matched file
+func RunPredict(opts PredictOptions) {
+	entries, err := loadAnalyticsEntries(AnalyticsOptions{
+		InputFile:       opts.InputFile,
+		IncludeDisabled: opts.IncludeDisabled,
+		IncludeMachines: opts.IncludeMachines,
+		Manifest:        opts.Manifest,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+
+	var accounts []AccountPrediction
+	for _, entry := range entries {
+		if !entry.Cracked {
+			continue
+		}
+		guesses := predict.Predict(entry.Password, opts.Direction, opts.Steps)
+		if len(guesses) > 0 {
+			accounts = append(accounts, AccountPrediction{entry.Username, entry.Password, guesses})
+		}
+	}
+
+	err = writeOutput(opts.OutFile, func(output io.Writer) error {
+		if opts.PerAccount {
+			return writeAccountPredictions(output, opts.Format, accounts)
+		}
+		return writePredictedPasswords(output, opts.Format, globalPredictions(accounts))
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "[+] Predicted passwords for %d cracked accounts\n", len(accounts))
+	if opts.OutFile != "" {
+		fmt.Fprintf(os.Stderr, "[+] Predictions written to: %s\n", opts.OutFile)
+	}
+}
+
+// globalPredictions merges the predictions of all accounts, ordered by the
+// number of accounts predicting them, then by closeness
+func globalPredictions(accounts []AccountPrediction) []PredictedPassword {
+	index := make(map[string]int)
+	var passwords []PredictedPassword
+	for _, account := range accounts {
+		for _, guess := range account.Guesses {
+			i, ok := index[guess.Password]
+			if !ok {
+				index[guess.Password] = len(passwords)
+				passwords = append(passwords, PredictedPassword{Password: guess.Password, Accounts: 1, Step: guess.Step})
+				continue
+			}
+			passwords[i].Accounts++
+			if closer(guess.Step, passwords[i].Step) {
+				passwords[i].Step = guess.Step
+			}
+		}
+	}
+
+	sort.SliceStable(passwords, func(i, j int) bool {
+		a, b := passwords[i], passwords[j]
+		if a.Accounts != b.Accounts {
+			return a.Accounts > b.Accounts
+		}
+		if a.Step != b.Step {
+			return closer(a.Step, b.Step)
+		}
+		return a.Password < b.Password
+	})
+	return passwords
+}
+
+// closer reports whether step a is closer than b, next before previous
+func closer(a, b int) bool {
+	abs := func(n int) int {
+		if n < 0 {
+			return -n
+		}
+		return n
+	}
+	if abs(a) != abs(b) {
+		return abs(a) < abs(b)
+	}
+	return a > b
+}
+
+// writeAccountPredictions writes the per-account predictions: user:password
+// lines in text format, closest first
+func writeAccountPredictions(output io.Writer, format string, accounts []AccountPrediction) error {
+	if format != FormatText {
+		return writeRecords(output, format, accounts)
+	}
+	w := bufio.NewWriter(output)
+	for _, account := range accounts {
+		for _, guess := range account.Guesses {
+			fmt.Fprintf(w, "%s:%s\n", account.Username, guess.Password)
+		}
+	}
+	return w.Flush()
+}
+
+// writePredictedPasswords writes the global predictions: a wordlist in
+// text format
+func writePredictedPasswords(output io.Writer, format string, passwords []PredictedPassword) error {
+	if format != FormatText {
+		return writeRecords(output, format, passwords)
+	}
+	w := bufio.NewWriter(output)
+	for _, password := range passwords {
+		fmt.Fprintln(w, password.Password)
+	}
+	return w.Flush()
+}
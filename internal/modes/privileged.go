@@ -0,0 +1,68 @@
+package modes
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+)
+
+// percentage returns part/total*100, or 0 when total is 0
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// ComputePrivilegedStats computes privileged/non-privileged statistics for tagged entries.
+// An account counts as reused when its NT hash is shared with any other analyzed account.
+func ComputePrivilegedStats(entries []*ntds.CrackedEntry) *ntds.PrivilegedStats {
+	hashUsers := make(map[string]int)
+	hashNonPrivileged := make(map[string]int)
+	for _, entry := range entries {
+		hash := strings.ToLower(entry.NTHash)
+		hashUsers[hash]++
+		if !entry.IsPrivileged() {
+			hashNonPrivileged[hash]++
+		}
+	}
+
+	stats := &ntds.PrivilegedStats{CrackedAccounts: []ntds.PrivilegedAccount{}}
+	for _, entry := range entries {
+		hash := strings.ToLower(entry.NTHash)
+		segment := &stats.NonPrivileged
+		if entry.IsPrivileged() {
+			segment = &stats.Privileged
+			if entry.Cracked {
+				stats.CrackedAccounts = append(stats.CrackedAccounts, ntds.PrivilegedAccount{
+					Username: entry.Username,
+					Password: entry.Password,
+					Groups:   entry.PrivilegedGroups,
+				})
+			}
+			if hashNonPrivileged[hash] > 0 {
+				stats.SharedWithNonPrivileged++
+			}
+		}
+
+		segment.Accounts++
+		if entry.Cracked {
+			segment.Cracked++
+		}
+		if hashUsers[hash] > 1 {
+			segment.Reused++
+		}
+	}
+
+	for _, segment := range []*ntds.SegmentStats{&stats.Privileged, &stats.NonPrivileged} {
+		segment.CrackPercentage = percentage(segment.Cracked, segment.Accounts)
+		segment.ReusePercentage = percentage(segment.Reused, segment.Accounts)
+	}
+
+	sort.Slice(stats.CrackedAccounts, func(i, j int) bool {
+		return strings.ToLower(stats.CrackedAccounts[i].Username) < strings.ToLower(stats.CrackedAccounts[j].Username)
+	})
+
+	return stats
+}
@@ -0,0 +1,80 @@
+package modes
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+)
+
+// svgDonut renders a donut chart of a percentage with a centered label
+func svgDonut(pct float64, color string) template.HTML {
+	const radius = 60.0
+	circumference := 2 * math.Pi * radius
+	filled := pct / 100 * circumference
+
+	return template.HTML(fmt.Sprintf(`<svg class="donut" viewBox="0 0 160 160" width="160" height="160" role="img" aria-label="%.1f%%">`+
+		`<circle cx="80" cy="80" r="%.0f" fill="none" stroke="#e5e7eb" stroke-width="22"/>`+
+		`<circle cx="80" cy="80" r="%.0f" fill="none" stroke="%s" stroke-width="22" stroke-dasharray="%.2f %.2f" transform="rotate(-90 80 80)"/>`+
+		`<text x="80" y="87" text-anchor="middle" font-size="22" font-weight="bold" fill="#111827">%.1f%%</text></svg>`,
+		pct, radius, radius, color, filled, circumference-filled, pct))
+}
+
+// svgHistogram renders a vertical bar chart of the password length distribution
+func svgHistogram(dist map[int]int) template.HTML {
+	if len(dist) == 0 {
+		return ""
+	}
+
+	var lengths []int
+	maxCount := 0
+	for length, count := range dist {
+		lengths = append(lengths, length)
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	sort.Ints(lengths)
+
+	const chartHeight = 180.0
+	const barWidth = 28.0
+	const gap = 8.0
+	width := float64(len(lengths))*(barWidth+gap) + gap
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %.0f %.0f" width="%.0f" height="%.0f" role="img" aria-label="Password length distribution">`,
+		width, chartHeight+40, width, chartHeight+40)
+	for i, length := range lengths {
+		count := dist[length]
+		height := float64(count) / float64(maxCount) * chartHeight
+		x := gap + float64(i)*(barWidth+gap)
+		y := chartHeight - height + 16
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.0f" height="%.1f" fill="#2563eb"><title>%d chars: %d</title></rect>`,
+			x, y, barWidth, height, length, count)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle" font-size="10" fill="#374151">%d</text>`,
+			x+barWidth/2, y-3, count)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.0f" text-anchor="middle" font-size="11" fill="#374151">%d</text>`,
+			x+barWidth/2, chartHeight+32, length)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// svgStackedBar renders a horizontal bar split into compliant and non-compliant parts
+func svgStackedBar(pct float64) template.HTML {
+	const width = 480.0
+	filled := pct / 100 * width
+	return template.HTML(fmt.Sprintf(`<svg class="chart" viewBox="0 0 %.0f 28" width="%.0f" height="28" role="img" aria-label="%.1f%% compliant">`+
+		`<rect x="0" y="0" width="%.0f" height="28" rx="4" fill="#fca5a5"/>`+
+		`<rect x="0" y="0" width="%.2f" height="28" rx="4" fill="#16a34a"/></svg>`,
+		width, width, pct, width, filled))
+}
+
+// writeHTMLReport writes the analytics result as a self-contained HTML page
+func writeHTMLReport(w io.Writer, result *ntds.AnalyticsResult, opts AnalyticsOptions) error {
+	return writeTemplateReport(w, NewReportData(result, opts), "report.html", defaultHTMLTemplate, true)
+}
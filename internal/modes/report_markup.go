@@ -0,0 +1,245 @@
+package modes
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// markupWriter emits headings and tables for a lightweight markup language
+type markupWriter interface {
+	heading(level int, title string)
+	paragraph(text string)
+	table(header []string, rows [][]string)
+	escape(text string) string
+	code(text string) string
+}
+
+type markdownWriter struct {
+	write func(format string, args ...interface{})
+}
+
+func (m *markdownWriter) heading(level int, title string) {
+	m.write("%s %s\n\n", strings.Repeat("#", level), title)
+}
+
+func (m *markdownWriter) paragraph(text string) {
+	m.write("%s\n\n", text)
+}
+
+func (m *markdownWriter) table(header []string, rows [][]string) {
+	m.write("| %s |\n", strings.Join(header, " | "))
+	seps := make([]string, len(header))
+	for i := range seps {
+		seps[i] = "---"
+	}
+	m.write("|%s|\n", strings.Join(seps, "|"))
+	for _, row := range rows {
+		m.write("| %s |\n", strings.Join(row, " | "))
+	}
+	m.write("\n")
+}
+
+// controlEscaper keeps tabs and line breaks in passwords from breaking table rows
+var controlEscaper = strings.NewReplacer("\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "|", "\\|", "*", "\\*", "_", "\\_", "`", "\\`",
+	"<", "&lt;", ">", "&gt;", "[", "\\[", "]", "\\]", "#", "\\#",
+)
+
+func (m *markdownWriter) escape(text string) string {
+	return controlEscaper.Replace(markdownEscaper.Replace(text))
+}
+
+func (m *markdownWriter) code(text string) string {
+	// Backticks and pipes cannot be escaped inside a code span in tables
+	if strings.ContainsAny(text, "`|") {
+		return m.escape(text)
+	}
+	return "`" + controlEscaper.Replace(text) + "`"
+}
+
+type asciidocWriter struct {
+	write func(format string, args ...interface{})
+}
+
+func (a *asciidocWriter) heading(level int, title string) {
+	a.write("%s %s\n\n", strings.Repeat("=", level), title)
+}
+
+func (a *asciidocWriter) paragraph(text string) {
+	a.write("%s\n\n", text)
+}
+
+func (a *asciidocWriter) table(header []string, rows [][]string) {
+	a.write("[%%header,cols=\"%d*\"]\n|===\n", len(header))
+	a.write("|%s\n\n", strings.Join(header, " |"))
+	for _, row := range rows {
+		a.write("|%s\n", strings.Join(row, " |"))
+	}
+	a.write("|===\n\n")
+}
+
+// asciidocPassEscaper escapes the characters ending a pass:c[] macro and a
+// table cell
+var asciidocPassEscaper = strings.NewReplacer("]", "\\]", "|", "\\|")
+
+func (a *asciidocWriter) escape(text string) string {
+	// pass:c[] keeps AsciiDoc from interpreting the value while still
+	// escaping <, > and & for HTML. A trailing backslash would escape the
+	// closing bracket, so it is written as an attribute reference instead.
+	if text == "" {
+		return ""
+	}
+	trimmed := strings.TrimRight(text, "\\")
+	return "pass:c[" + asciidocPassEscaper.Replace(controlEscaper.Replace(trimmed)) + "]" +
+		strings.Repeat("{backslash}", len(text)-len(trimmed))
+}
+
+func (a *asciidocWriter) code(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "`" + a.escape(text) + "`"
+}
+
+// writeMarkupReport writes the analytics report sections as Markdown or AsciiDoc
+func writeMarkupReport(w io.Writer, result *ntds.AnalyticsResult, opts AnalyticsOptions) error {
+	var writeErr error
+	writeFunc := func(format string, args ...interface{}) {
+		if writeErr == nil {
+			_, writeErr = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	var m markupWriter
+	if opts.Format == FormatAsciiDoc {
+		m = &asciidocWriter{write: writeFunc}
+	} else {
+		m = &markdownWriter{write: writeFunc}
+	}
+
+	displayPwd := func(password string) string {
+		if password == "" {
+			return m.escape("<empty>")
+		}
+		return m.code(password)
+	}
+
+	m.heading(1, "Password Analytics Report")
+	m.table([]string{"Filter", "Setting"}, [][]string{
+		{"Disabled accounts", utils.BoolToIncluded(result.IncludeDisabled)},
+		{"Machine accounts", utils.BoolToIncluded(result.IncludeMachines)},
+	})
+
+	// Cracked Privileged Accounts
+	if privStats := result.Privileged; privStats != nil {
+		m.heading(2, "Cracked Privileged Accounts")
+		m.paragraph(fmt.Sprintf("%d of %d privileged accounts were cracked.", privStats.Privileged.Cracked, privStats.Privileged.Accounts))
+
+		if len(privStats.CrackedAccounts) > 0 {
+			var rows [][]string
+			for _, account := range privStats.CrackedAccounts {
+				rows = append(rows, []string{m.escape(account.Username), displayPwd(account.Password), m.escape(strings.Join(account.Groups, ", "))})
+			}
+			m.table([]string{"Account", "Password", "Groups"}, rows)
+		}
+
+		var rows [][]string
+		for _, row := range []struct {
+			label string
+			stats ntds.SegmentStats
+		}{
+			{"Privileged", privStats.Privileged},
+			{"Non-privileged", privStats.NonPrivileged},
+		} {
+			rows = append(rows, []string{row.label, fmt.Sprint(row.stats.Accounts), fmt.Sprint(row.stats.Cracked),
+				fmt.Sprintf("%.2f%%", row.stats.CrackPercentage), fmt.Sprint(row.stats.Reused), fmt.Sprintf("%.2f%%", row.stats.ReusePercentage)})
+		}
+		m.table([]string{"Segment", "Accounts", "Cracked", "Crack %", "Reused", "Reuse %"}, rows)
+		m.paragraph(fmt.Sprintf("Privileged accounts sharing a hash with non-privileged accounts: %d", privStats.SharedWithNonPrivileged))
+	}
+
+	// General Statistics
+	m.heading(2, "General Statistics")
+	m.table([]string{"Metric", "Count", "Percentage"}, [][]string{
+		{"Total accounts analyzed", fmt.Sprint(result.TotalAccounts), "100.00%"},
+		{"Passwords cracked", fmt.Sprint(result.CrackedAccounts), fmt.Sprintf("%.2f%%", result.CrackPercentage)},
+		{"Passwords not cracked", fmt.Sprint(result.TotalAccounts - result.CrackedAccounts), fmt.Sprintf("%.2f%%", 100-result.CrackPercentage)},
+	})
+
+	// Password Length Distribution
+	m.heading(2, "Password Length Distribution")
+	if len(result.LengthDistribution) > 0 {
+		var lengths []int
+		for length := range result.LengthDistribution {
+			lengths = append(lengths, length)
+		}
+		sort.Ints(lengths)
+
+		var rows [][]string
+		for _, length := range lengths {
+			count := result.LengthDistribution[length]
+			rows = append(rows, []string{fmt.Sprint(length), fmt.Sprint(count), fmt.Sprintf("%.1f%%", percentage(count, result.CrackedAccounts))})
+		}
+		m.table([]string{"Length", "Count", "Percentage"}, rows)
+	} else {
+		m.paragraph("No cracked passwords to analyze.")
+	}
+
+	// Top 10 Passwords
+	m.heading(2, "Top 10 Most Used Passwords")
+	if len(result.TopPasswords) > 0 || result.Suppressed.Accounts > 0 {
+		var rows [][]string
+		for i, pwd := range result.TopPasswords {
+			rows = append(rows, []string{fmt.Sprint(i + 1), displayPwd(pwd.Password), fmt.Sprint(pwd.Count)})
+		}
+		if s := result.Suppressed; s.Accounts > 0 {
+			rows = append(rows, []string{"-", m.escape(fmt.Sprintf("Other (used by < %d accounts)", s.MinShare)), fmt.Sprint(s.Accounts)})
+		}
+		m.table([]string{"Rank", "Password", "Count"}, rows)
+	} else {
+		m.paragraph("No cracked passwords to analyze.")
+	}
+
+	// Password Policy Compliance
+	if opts.ShowPasspol {
+		m.heading(2, "Password Policy Compliance")
+		m.paragraph("Policy: DOMAIN_PASSWORD_COMPLEX. Passwords must be at least 8 characters long and contain " +
+			"at least 3 of 4 categories: uppercase letters, lowercase letters, digits and special characters.")
+		m.table([]string{"Result", "Count", "Percentage of cracked"}, [][]string{
+			{"Compliant", fmt.Sprint(result.ComplexCount), fmt.Sprintf("%.2f%%", result.ComplexPercentage)},
+			{"Non-compliant", fmt.Sprint(result.CrackedAccounts - result.ComplexCount), fmt.Sprintf("%.2f%%", 100-result.ComplexPercentage)},
+		})
+	}
+
+	// Admin/User Paired Accounts
+	if result.Pairs != nil {
+		m.heading(2, "Admin/User Paired Account Reuse")
+		if len(result.Pairs) > 0 {
+			var rows [][]string
+			for _, pair := range result.Pairs {
+				finding := "Identical NT hash"
+				detail := ""
+				if pair.NTHash != "" {
+					detail = m.code(pair.NTHash)
+				}
+				if pair.Kind == ntds.PairSimilarPassword {
+					finding = fmt.Sprintf("Similar passwords (distance %d)", pair.Distance)
+					detail = displayPwd(pair.UserPassword) + " / " + displayPwd(pair.AdminPassword)
+				}
+				rows = append(rows, []string{m.escape(pair.User), m.escape(pair.Admin), finding, detail})
+			}
+			m.table([]string{"User", "Admin", "Finding", "Detail"}, rows)
+		} else {
+			m.paragraph("No paired accounts with reused credentials found.")
+		}
+	}
+
+	return writeErr
+}
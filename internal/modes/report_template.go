@@ -0,0 +1,195 @@
+package modes
+
+import (
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/fisher0x/hashtocrack/internal/manifest"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/redact"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+//go:embed templates/report.txt.tmpl
+var defaultTextTemplate string
+
+//go:embed templates/report.html.tmpl
+var defaultHTMLTemplate string
+
+// ReportData is the data model passed to report templates. It embeds the
+// analytics result, so every result field is available directly (e.g. .TotalAccounts).
+type ReportData struct {
+	*ntds.AnalyticsResult
+
+	ShowPasspol     bool
+	ShowPairs       bool
+	PairMaxDistance int
+
+	NotCracked           int
+	NotCrackedPercentage float64
+	Lengths              []LengthBucket
+	MaxLengthCount       int
+	Compliance           ComplianceSection
+	PairCounts           PairSection
+
+	// MinShare is the k-anonymity threshold (0 when off) and SuppressedAccounts
+	// the accounts aggregated into the "other" top passwords line
+	MinShare           int
+	SuppressedAccounts int
+
+	// Manifest is the evidence manifest of the run, when one is written
+	Manifest *manifest.Manifest
+
+	// redactor backs the redact template function
+	redactor *redact.Redactor
+}
+
+// LengthBucket is one row of the password length distribution
+type LengthBucket struct {
+	Length     int
+	Count      int
+	Percentage float64
+}
+
+// ComplianceSection holds the DOMAIN_PASSWORD_COMPLEX compliance figures
+type ComplianceSection struct {
+	Compliant              int
+	CompliantPercentage    float64
+	NonCompliant           int
+	NonCompliantPercentage float64
+}
+
+// PairSection holds the admin/user pair counts by kind
+type PairSection struct {
+	SameHash        int
+	SimilarPassword int
+}
+
+// NewReportData builds the template data model for an analytics result
+func NewReportData(result *ntds.AnalyticsResult, opts AnalyticsOptions) *ReportData {
+	data := &ReportData{
+		AnalyticsResult:      result,
+		ShowPasspol:          opts.ShowPasspol,
+		ShowPairs:            result.Pairs != nil,
+		PairMaxDistance:      opts.PairMaxDistance,
+		Manifest:             opts.Manifest,
+		redactor:             opts.Redactor,
+		NotCracked:           result.TotalAccounts - result.CrackedAccounts,
+		NotCrackedPercentage: 100 - result.CrackPercentage,
+		Compliance: ComplianceSection{
+			Compliant:              result.ComplexCount,
+			CompliantPercentage:    result.ComplexPercentage,
+			NonCompliant:           result.CrackedAccounts - result.ComplexCount,
+			NonCompliantPercentage: 100 - result.ComplexPercentage,
+		},
+	}
+	data.MinShare = result.Suppressed.MinShare
+	data.SuppressedAccounts = result.Suppressed.Accounts
+	if data.redactor == nil {
+		data.redactor = redact.Default()
+	}
+
+	for length, count := range result.LengthDistribution {
+		data.Lengths = append(data.Lengths, LengthBucket{
+			Length:     length,
+			Count:      count,
+			Percentage: percentage(count, result.CrackedAccounts),
+		})
+		if count > data.MaxLengthCount {
+			data.MaxLengthCount = count
+		}
+	}
+	sort.Slice(data.Lengths, func(i, j int) bool {
+		return data.Lengths[i].Length < data.Lengths[j].Length
+	})
+
+	for _, pair := range result.Pairs {
+		if pair.Kind == ntds.PairSameHash {
+			data.PairCounts.SameHash++
+		} else {
+			data.PairCounts.SimilarPassword++
+		}
+	}
+
+	return data
+}
+
+// templateFuncs returns the helper functions available to report templates
+func templateFuncs(html bool, redactor *redact.Redactor) map[string]interface{} {
+	funcs := map[string]interface{}{
+		"redact":   redactor.Redact,
+		"percent":  percentage,
+		"included": utils.BoolToIncluded,
+		"join":     strings.Join,
+		"inc":      func(i int) int { return i + 1 },
+		"sub":      func(a, b int) int { return a - b },
+		"repeat":   strings.Repeat,
+		// bar renders a progress bar of the given width for a percentage
+		"bar": func(pct float64, width int) string {
+			filled := int(pct / 100 * float64(width))
+			return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+		},
+		// scaled renders a bar proportional to count/max
+		"scaled": func(count, max, width int) string {
+			if max == 0 {
+				return ""
+			}
+			return strings.Repeat("▓", int(float64(count)/float64(max)*float64(width)))
+		},
+		// display shows empty passwords explicitly
+		"display": func(password string) string {
+			if password == "" {
+				return "<empty>"
+			}
+			return password
+		},
+		// truncate shortens long values to fit a table column
+		"truncate": utils.Truncate,
+	}
+	if html {
+		funcs["donut"] = svgDonut
+		funcs["histogram"] = svgHistogram
+		funcs["stackedBar"] = svgStackedBar
+	}
+	return funcs
+}
+
+// isHTMLTemplate reports whether a template should be rendered with html/template
+func isHTMLTemplate(filename, format string) bool {
+	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(strings.ToLower(filename), ".tmpl")))
+	return format == FormatHTML || ext == ".html" || ext == ".htm"
+}
+
+// writeTemplateReport renders the report data through a text or HTML template
+func writeTemplateReport(w io.Writer, data *ReportData, name, text string, html bool) error {
+	if html {
+		tmpl, err := htmltemplate.New(name).Funcs(templateFuncs(true, data.redactor)).Parse(text)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, data)
+	}
+
+	tmpl, err := texttemplate.New(name).Funcs(templateFuncs(false, data.redactor)).Parse(text)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
+
+// writeUserTemplateReport renders the report with a user-supplied template file
+func writeUserTemplateReport(w io.Writer, result *ntds.AnalyticsResult, opts AnalyticsOptions) error {
+	text, err := os.ReadFile(opts.TemplateFile)
+	if err != nil {
+		return fmt.Errorf("reading template: %v", err)
+	}
+	html := isHTMLTemplate(opts.TemplateFile, opts.Format)
+	return writeTemplateReport(w, NewReportData(result, opts), filepath.Base(opts.TemplateFile), string(text), html)
+}
@@ -0,0 +1,120 @@
+package modes
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/redact"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+	"github.com/fisher0x/hashtocrack/internal/xlsx"
+)
+
+// addAccountsSheet adds the per-account results sheet
+func addAccountsSheet(wb *xlsx.Workbook, accounts []ntds.AccountSummary) {
+	sheet := wb.AddSheet("Accounts", "Username", "Status", "Cracked", "Password", "Length",
+		"Compliant", "Policy Failures", "Reuse Cluster", "Privileged Groups")
+	for _, account := range accounts {
+		var password, length, compliant, cluster interface{}
+		if account.Cracked {
+			password, length, compliant = account.Password, account.Length, account.Compliant
+		}
+		if account.ReuseCluster > 0 {
+			cluster = account.ReuseCluster
+		}
+		sheet.AddRow(account.Username, account.Status, account.Cracked, password, length, compliant,
+			strings.Join(account.PolicyFailures, "; "), cluster, strings.Join(account.PrivilegedGroups, ", "))
+	}
+}
+
+// writeXLSXRecords writes match results as a workbook with a single accounts
+// sheet. Summaries are built from the plaintext passwords, then redacted; a
+// workbook is meant to be shared, so passwords are redacted by default.
+func writeXLSXRecords(w io.Writer, results []*ntds.CrackedEntry, redactor *redact.Redactor) error {
+	if redactor == nil {
+		redactor = redact.Default()
+	}
+	accounts := AccountSummaries(results)
+	for i := range accounts {
+		accounts[i].Password = redactor.Redact(accounts[i].Password)
+	}
+
+	wb := xlsx.New()
+	addAccountsSheet(wb, accounts)
+	return wb.Write(w)
+}
+
+// writeXLSXReport writes the analytics result as a workbook with one sheet per table
+func writeXLSXReport(w io.Writer, result *ntds.AnalyticsResult, opts AnalyticsOptions) error {
+	wb := xlsx.New()
+
+	summary := wb.AddSheet("Summary", "Metric", "Value", "Percentage")
+	summary.AddRow("Disabled accounts", utils.BoolToIncluded(result.IncludeDisabled), nil)
+	summary.AddRow("Machine accounts", utils.BoolToIncluded(result.IncludeMachines), nil)
+	summary.AddRow("Total accounts analyzed", result.TotalAccounts, 100.0)
+	summary.AddRow("Passwords cracked", result.CrackedAccounts, result.CrackPercentage)
+	summary.AddRow("Passwords not cracked", result.TotalAccounts-result.CrackedAccounts, 100-result.CrackPercentage)
+
+	addAccountsSheet(wb, result.Accounts)
+
+	lengths := wb.AddSheet("Length Distribution", "Length", "Count", "Percentage")
+	var sorted []int
+	for length := range result.LengthDistribution {
+		sorted = append(sorted, length)
+	}
+	sort.Ints(sorted)
+	for _, length := range sorted {
+		count := result.LengthDistribution[length]
+		lengths.AddRow(length, count, percentage(count, result.CrackedAccounts))
+	}
+
+	top := wb.AddSheet("Top Passwords", "Rank", "Password", "Count")
+	for i, pwd := range result.TopPasswords {
+		top.AddRow(i+1, pwd.Password, pwd.Count)
+	}
+	if s := result.Suppressed; s.Accounts > 0 {
+		top.AddRow(nil, fmt.Sprintf("Other (used by < %d accounts)", s.MinShare), s.Accounts)
+	}
+
+	if opts.ShowPasspol {
+		policy := wb.AddSheet("Policy Compliance", "Result", "Count", "Percentage of cracked")
+		policy.AddRow("Compliant", result.ComplexCount, result.ComplexPercentage)
+		policy.AddRow("Non-compliant", result.CrackedAccounts-result.ComplexCount, 100-result.ComplexPercentage)
+	}
+
+	if result.Pairs != nil {
+		pairs := wb.AddSheet("Paired Accounts", "User", "Admin", "Pattern", "Finding", "NT Hash",
+			"User Password", "Admin Password", "Distance")
+		for _, pair := range result.Pairs {
+			var distance interface{}
+			if pair.Kind == ntds.PairSimilarPassword {
+				distance = pair.Distance
+			}
+			pairs.AddRow(pair.User, pair.Admin, pair.Pattern, pair.Kind, pair.NTHash,
+				pair.UserPassword, pair.AdminPassword, distance)
+		}
+	}
+
+	if privStats := result.Privileged; privStats != nil {
+		segments := wb.AddSheet("Privileged Segments", "Segment", "Accounts", "Cracked", "Crack %", "Reused", "Reuse %")
+		for _, row := range []struct {
+			label string
+			stats ntds.SegmentStats
+		}{
+			{"Privileged", privStats.Privileged},
+			{"Non-privileged", privStats.NonPrivileged},
+		} {
+			segments.AddRow(row.label, row.stats.Accounts, row.stats.Cracked, row.stats.CrackPercentage,
+				row.stats.Reused, row.stats.ReusePercentage)
+		}
+
+		cracked := wb.AddSheet("Privileged Cracked", "Account", "Password", "Groups")
+		for _, account := range privStats.CrackedAccounts {
+			cracked.AddRow(account.Username, account.Password, strings.Join(account.Groups, ", "))
+		}
+	}
+
+	return wb.Write(w)
+}
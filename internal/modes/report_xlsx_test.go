@@ -0,0 +1,74 @@
+package modes
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fisher0x/hashtocrack/internal/redact"
+)
+
+// workbookText returns the worksheets of a workbook, concatenated
+func workbookText(t *testing.T, data []byte) string {
+	t.Helper()
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	for _, f := range z.File {
+		if !strings.HasPrefix(f.Name, "xl/worksheets/") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		text.Write(data)
+	}
+	return text.String()
+}
+
+func TestXLSXRedactedByDefault(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "matched.txt")
+	writeMatchedFile(t, input, anonymizeRecords, "")
+
+	tests := []struct {
+		name     string
+		redactor *redact.Redactor
+		want     string
+	}{
+		{"default", nil, "pas*****"},
+		{"strategy", &redact.Redactor{Strategy: redact.Length}, "********"},
+	}
+	for _, tt := range tests {
+		var match bytes.Buffer
+		if err := writeXLSXRecords(&match, anonymizeRecords, tt.redactor); err != nil {
+			t.Fatal(err)
+		}
+
+		output := filepath.Join(dir, tt.name+".xlsx")
+		RunAnalytics(AnalyticsOptions{InputFile: input, OutFile: output, Format: FormatXLSX, Redactor: tt.redactor})
+		analytics, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for workbook, data := range map[string][]byte{"match": match.Bytes(), "analytics": analytics} {
+			text := workbookText(t, data)
+			if strings.Contains(text, ">password<") || !strings.Contains(text, ">"+tt.want+"<") {
+				t.Errorf("%s: %s workbook not redacted to %s", tt.name, workbook, tt.want)
+			}
+		}
+	}
+}
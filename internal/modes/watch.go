@@ -0,0 +1,360 @@
+package modes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// DefaultWatchInterval is how often -watch polls the potfile
+const DefaultWatchInterval = 2 * time.Second
+
+// rateWindow is the period of the recent crack rate in the watch status
+const rateWindow = 10 * time.Minute
+
+// potTail follows a growing potfile. It detects truncation (the file got
+// shorter) and rotation (the path now names another file), and rereads the
+// file from the start in both cases.
+type potTail struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+}
+
+// poll passes every complete line added since the last poll to fn. It
+// returns "truncated" or "rotated" when the file was reset.
+func (t *potTail) poll(fn func(line string)) (string, error) {
+	info, err := os.Stat(t.path)
+	if os.IsNotExist(err) && t.file != nil {
+		// Rotated away and not yet recreated: finish the old file
+		return "", t.read(fn)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	event := ""
+	switch {
+	case t.file == nil:
+	case !os.SameFile(t.info, info):
+		if err := t.read(fn); err != nil {
+			return "", err
+		}
+		t.file.Close()
+		t.file = nil
+		event = "rotated"
+	case info.Size() < t.offset:
+		t.offset, t.partial = 0, nil
+		event = "truncated"
+	}
+
+	if t.file == nil {
+		file, err := os.Open(t.path)
+		if err != nil {
+			return "", err
+		}
+		magic := make([]byte, len(seal.Magic))
+		n, _ := io.ReadFull(file, magic)
+		if seal.IsSealed(magic[:n]) {
+			file.Close()
+			return "", fmt.Errorf("%s is encrypted and cannot be followed", t.path)
+		}
+		t.file, t.offset, t.partial = file, 0, nil
+	}
+	t.info = info
+	return event, t.read(fn)
+}
+
+// read passes the complete lines from the offset to the end of the file
+func (t *potTail) read(fn func(line string)) error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := t.file.ReadAt(buf, t.offset)
+		t.offset += int64(n)
+		data := append(t.partial, buf[:n]...)
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			fn(string(data[:i]))
+			data = data[i+1:]
+		}
+		// hashcat appends whole lines; a partial line is kept for the next poll
+		t.partial = append([]byte(nil), data...)
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the followed file
+func (t *potTail) Close() {
+	if t.file != nil {
+		t.file.Close()
+	}
+}
+
+// watchStatus tracks the progress shown in the live status line
+type watchStatus struct {
+	total      int
+	cracked    int
+	started    time.Time
+	newCracked int
+	// newPrivileged are the privileged accounts cracked while watching
+	newPrivileged int
+	recent        []time.Time
+	terminal      bool
+}
+
+// add records accounts cracked at the given time
+func (s *watchStatus) add(count int, now time.Time) {
+	s.cracked += count
+	s.newCracked += count
+	for i := 0; i < count; i++ {
+		s.recent = append(s.recent, now)
+	}
+}
+
+// line formats the status line
+func (s *watchStatus) line(now time.Time) string {
+	for len(s.recent) > 0 && now.Sub(s.recent[0]) > rateWindow {
+		s.recent = s.recent[1:]
+	}
+	elapsed := now.Sub(s.started)
+	window := rateWindow
+	if elapsed < window {
+		window = elapsed
+	}
+	perMinute := func(count int, d time.Duration) float64 {
+		if d < time.Second {
+			return 0
+		}
+		return float64(count) / d.Minutes()
+	}
+
+	percent := 0.0
+	if s.total > 0 {
+		percent = float64(s.cracked) / float64(s.total) * 100
+	}
+	return fmt.Sprintf("[watch] %d/%d cracked (%.1f%%) | +%d new | %d privileged new | %.1f/min last %.0fm, %.1f/min overall | %s",
+		s.cracked, s.total, percent, s.newCracked, s.newPrivileged,
+		perMinute(len(s.recent), window), rateWindow.Minutes(), perMinute(s.newCracked, elapsed),
+		elapsed.Truncate(time.Second))
+}
+
+// print shows the status line: rewritten in place on a terminal, or as a
+// new line when something changed otherwise
+func (s *watchStatus) print(changed bool, now time.Time) {
+	if s.terminal {
+		fmt.Fprintf(os.Stderr, "\r%s\x1b[K", s.line(now))
+	} else if changed {
+		fmt.Fprintln(os.Stderr, s.line(now))
+	}
+}
+
+// message prints a line above the status line
+func (s *watchStatus) message(format string, args ...interface{}) {
+	if s.terminal {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// watchMatch writes the match results, then follows the potfile and appends
+// a record for every newly cracked account until interrupted. A later record
+// of an account replaces its earlier one (see ntds.LatestRecords).
+func watchMatch(opts MatchOptions) {
+	interval := opts.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	var membership *ntds.GroupMembership
+	if len(opts.GroupFiles) > 0 {
+		var err error
+		membership, err = ntds.LoadGroupMembership(opts.GroupFiles, opts.ExtraPrivileged)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading group membership: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	tail := &potTail{path: opts.CrackFile}
+	defer tail.Close()
+	potfile := make(map[string]string)
+	load := func(line string) {
+		if hash, password, ok := ntds.ParsePotLine(line); ok {
+			potfile[hash] = password
+		}
+	}
+	if _, err := tail.poll(load); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading potfile: %v\n", err)
+		os.Exit(1)
+	}
+	// The last line of an existing potfile may lack its line break
+	if len(tail.partial) > 0 {
+		load(string(tail.partial))
+		tail.partial = nil
+	}
+
+	results, skipped, err := matchEntries(opts, potfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+
+	// Uncracked accounts by NT hash, and the initial progress
+	pending := make(map[string][]*ntds.CrackedEntry)
+	status := &watchStatus{total: len(results), started: time.Now(), terminal: isTerminal(os.Stderr)}
+	for _, result := range results {
+		if membership != nil {
+			membership.Tag(&result.Entry)
+		}
+		if result.Cracked {
+			status.cracked++
+		} else {
+			hash := strings.ToLower(result.NTHash)
+			pending[hash] = append(pending[hash], result)
+		}
+	}
+
+	// The output is appended to in place, so it is neither atomic nor
+	// encrypted
+	output := io.Writer(os.Stdout)
+	var file *os.File
+	if opts.OutFile != "" {
+		if err := utils.EnsureDir(opts.OutFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+			os.Exit(1)
+		}
+		file, err = os.OpenFile(opts.OutFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		output = file
+	}
+	write := func(records []*ntds.CrackedEntry) error {
+		if opts.Format == FormatJSONL {
+			return writeRecords(output, FormatJSONL, records)
+		}
+		for _, record := range records {
+			if _, err := fmt.Fprintln(output, ntds.FormatMatchedRecord(record)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	records := make([]*ntds.CrackedEntry, len(results))
+	for i, result := range results {
+		records[i] = redactRecord(result, opts.Redactor)
+	}
+	if opts.Format == FormatText {
+		err = ntds.WriteMatchedHeader(output, redaction(opts.Redactor))
+	}
+	if err == nil {
+		err = write(records)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+
+	// crack marks the accounts of a newly cracked hash and appends them
+	crack := func(line string) {
+		hash, password, ok := ntds.ParsePotLine(line)
+		if !ok || len(pending[hash]) == 0 {
+			return
+		}
+		accounts := pending[hash]
+		delete(pending, hash)
+
+		var appended []*ntds.CrackedEntry
+		for _, account := range accounts {
+			account.Cracked, account.Password = true, password
+			appended = append(appended, redactRecord(account, opts.Redactor))
+			if account.IsPrivileged() {
+				status.newPrivileged++
+				status.message("[+] Privileged account cracked: %s (%s)\n", account.Username, strings.Join(account.PrivilegedGroups, ", "))
+			}
+		}
+		if err := write(appended); err != nil {
+			fmt.Fprintf(os.Stderr, "\nError writing output: %v\n", err)
+			os.Exit(1)
+		}
+		status.add(len(accounts), time.Now())
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	fmt.Fprintf(os.Stderr, "[+] Watching %s every %s (Ctrl-C to stop)\n", opts.CrackFile, interval)
+	status.print(true, time.Now())
+
+watch:
+	for {
+		select {
+		case <-stop:
+			break watch
+		case <-ticker.C:
+		}
+
+		before := status.cracked
+		event, err := tail.poll(crack)
+		if err != nil {
+			status.message("[!] Error reading potfile: %v\n", err)
+			continue
+		}
+		if event != "" {
+			status.message("[!] Potfile %s, reading it again from the start\n", event)
+		}
+		status.print(status.cracked != before, time.Now())
+	}
+
+	if status.terminal {
+		fmt.Fprintln(os.Stderr)
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	opts.Manifest.SetCounts(len(results), skipped)
+
+	fmt.Fprintf(os.Stderr, "[+] Watch stopped: %d accounts cracked while watching, %d/%d in total\n",
+		status.newCracked, status.cracked, status.total)
+	if opts.OutFile != "" {
+		fmt.Fprintf(os.Stderr, "[+] Matched results written to: %s\n", opts.OutFile)
+	}
+
+	if opts.BloodHoundCypher != "" || opts.BloodHoundJSON != "" {
+		exportBloodHound(opts, results)
+	}
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
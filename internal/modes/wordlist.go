@@ -0,0 +1,164 @@
+package modes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fisher0x/hashtocrack/internal/manifest"
+	"github.com/fisher0x/hashtocrack/internal/ntds"
+	"github.com/fisher0x/hashtocrack/internal/ntlm"
+	"github.com/fisher0x/hashtocrack/internal/seal"
+	"github.com/fisher0x/hashtocrack/internal/wordlist"
+)
+
+// WordlistOptions holds the settings for the wordlist command
+type WordlistOptions struct {
+	NTDSFile        string
+	OutFile         string
+	IncludeDisabled bool
+	IncludeMachines bool
+	// KeywordFiles hold engagement keywords, one per line
+	KeywordFiles []string
+	// Years is the number of past years combined with the words
+	Years     int
+	MinLength int
+	// Limit keeps the most likely candidates only when positive
+	Limit int
+	// CheckPotfile receives the candidates matching an NT hash of the NTDS
+	// file when set
+	CheckPotfile string
+	Manifest     *manifest.Manifest
+}
+
+// RunWordlist writes the candidate passwords built from the NTDS accounts,
+// the domain names and the keywords, most likely first
+func RunWordlist(opts WordlistOptions) {
+	results, skipped, err := matchEntries(MatchOptions{
+		NTDSFile:        opts.NTDSFile,
+		IncludeDisabled: opts.IncludeDisabled,
+		IncludeMachines: opts.IncludeMachines,
+	}, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+	opts.Manifest.SetCounts(len(results), skipped)
+
+	generator := wordlist.New(time.Now(), opts.Years)
+	generator.MinLength = opts.MinLength
+	for _, result := range results {
+		domain, name := ntds.SplitUsername(result.Username)
+		if domain != "" {
+			generator.AddDomain(domain)
+		}
+		generator.AddAccount(name)
+	}
+	keywords := 0
+	for _, filename := range opts.KeywordFiles {
+		words, err := loadKeywords(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading keywords: %v\n", err)
+			os.Exit(1)
+		}
+		for _, word := range words {
+			generator.AddKeyword(word)
+		}
+		keywords += len(words)
+	}
+
+	candidates := generator.Candidates()
+	if opts.Limit > 0 && len(candidates) > opts.Limit {
+		candidates = candidates[:opts.Limit]
+	}
+
+	err = writeOutput(opts.OutFile, func(output io.Writer) error {
+		writer := bufio.NewWriter(output)
+		for _, candidate := range candidates {
+			fmt.Fprintln(writer, candidate.Password)
+		}
+		return writer.Flush()
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "[+] %d candidates from %d accounts and %d keywords\n", len(candidates), len(results), keywords)
+	if opts.OutFile != "" {
+		fmt.Fprintf(os.Stderr, "[+] Wordlist written to: %s\n", opts.OutFile)
+	}
+
+	if opts.CheckPotfile != "" {
+		checkCandidates(opts, results, candidates)
+	}
+}
+
+// loadKeywords reads a keyword file, skipping blank lines and # comments
+func loadKeywords(filename string) ([]string, error) {
+	data, err := seal.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, nil
+}
+
+// checkCandidates computes the NT hash of every candidate and appends the
+// ones matching an account not yet in the potfile to it, in hashcat's
+// hash:password format
+func checkCandidates(opts WordlistOptions, results []*ntds.CrackedEntry, candidates []wordlist.Candidate) {
+	var known map[string]string
+	if _, err := os.Stat(opts.CheckPotfile); err == nil {
+		known, err = ntds.LoadPotfile(opts.CheckPotfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading potfile: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Accounts by NT hash, for the hashes not yet cracked
+	pending := make(map[string]int)
+	for _, result := range results {
+		hash := strings.ToLower(result.NTHash)
+		if _, ok := known[hash]; !ok {
+			pending[hash]++
+		}
+	}
+
+	potfile := appendPotfile(opts.CheckPotfile)
+	hashes, accounts := 0, 0
+	for _, candidate := range candidates {
+		if len(pending) == 0 {
+			break
+		}
+		hash := ntlm.NTHash(candidate.Password)
+		count, ok := pending[hash]
+		if !ok {
+			continue
+		}
+		delete(pending, hash)
+		if _, err := fmt.Fprintf(potfile, "%s:%s\n", hash, ntds.EncodeHex(candidate.Password)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing potfile: %v\n", err)
+			os.Exit(1)
+		}
+		hashes++
+		accounts += count
+	}
+	if err := potfile.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing potfile: %v\n", err)
+		os.Exit(1)
+	}
+
+	recordOutput(opts.Manifest, "potfile", opts.CheckPotfile)
+	fmt.Fprintf(os.Stderr, "[+] Built-in check: %d new hashes cracked (%d accounts), appended to: %s\n", hashes, accounts, opts.CheckPotfile)
+}
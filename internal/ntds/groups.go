@@ -0,0 +1,388 @@
+package ntds
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/seal"
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// DefaultPrivilegedGroups are the built-in groups treated as privileged
+var DefaultPrivilegedGroups = []string{
+	"Domain Admins",
+	"Enterprise Admins",
+	"Schema Admins",
+	"Administrators",
+	"Account Operators",
+	"Backup Operators",
+	"Server Operators",
+	"Print Operators",
+	"Group Policy Creator Owners",
+	"Key Admins",
+	"Enterprise Key Admins",
+	"DnsAdmins",
+}
+
+// privilegedSIDSuffixes identify privileged groups by SID regardless of their (localized) name
+var privilegedSIDSuffixes = []string{
+	"-512", // Domain Admins
+	"-518", // Schema Admins
+	"-519", // Enterprise Admins
+	"-520", // Group Policy Creator Owners
+	"-526", // Key Admins
+	"-527", // Enterprise Key Admins
+}
+
+var privilegedBuiltinSIDs = []string{
+	"S-1-5-32-544", // Administrators
+	"S-1-5-32-548", // Account Operators
+	"S-1-5-32-549", // Server Operators
+	"S-1-5-32-550", // Print Operators
+	"S-1-5-32-551", // Backup Operators
+}
+
+// GroupMembership maps accounts to the privileged groups they belong to
+type GroupMembership struct {
+	privileged map[string]bool
+	// byName and byRID are keyed by lowercase account name and by RID; the
+	// memberships record the account's domain so that accounts of the same
+	// name or RID in other domains are not tagged
+	byName map[string][]membership
+	byRID  map[string][]membership
+}
+
+// membership is a privileged group of an account of a domain, given as a
+// NetBIOS name or an FQDN, or empty when the source does not tell
+type membership struct {
+	domain string
+	group  string
+}
+
+// NewGroupMembership creates an empty membership set; extra group names are
+// treated as privileged in addition to DefaultPrivilegedGroups
+func NewGroupMembership(extraGroups []string) *GroupMembership {
+	g := &GroupMembership{
+		privileged: make(map[string]bool),
+		byName:     make(map[string][]membership),
+		byRID:      make(map[string][]membership),
+	}
+	for _, name := range DefaultPrivilegedGroups {
+		g.privileged[strings.ToLower(name)] = true
+	}
+	for _, name := range extraGroups {
+		g.privileged[strings.ToLower(name)] = true
+	}
+	return g
+}
+
+// LoadGroupMembership loads group membership from ldapdomaindump JSON,
+// BloodHound/SharpHound JSON or group:member text files
+func LoadGroupMembership(filenames []string, extraGroups []string) (*GroupMembership, error) {
+	g := NewGroupMembership(extraGroups)
+
+	var bloodhound []bloodhoundFile
+	for _, filename := range filenames {
+		data, err := seal.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("[")):
+			err = g.loadLdapDomainDump(trimmed)
+		case bytes.HasPrefix(trimmed, []byte("{")):
+			var bh bloodhoundFile
+			if err = json.Unmarshal(trimmed, &bh); err == nil {
+				bloodhound = append(bloodhound, bh)
+			}
+		default:
+			err = g.loadText(trimmed)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+
+	// BloodHound files reference each other by SID, so resolve them together
+	if len(bloodhound) > 0 {
+		g.loadBloodHound(bloodhound)
+	}
+
+	return g, nil
+}
+
+// IsPrivileged reports whether a group name is considered privileged
+func (g *GroupMembership) IsPrivileged(group string) bool {
+	return g.privileged[strings.ToLower(group)]
+}
+
+// Tag sets the entry's privileged groups from the loaded membership
+func (g *GroupMembership) Tag(entry *Entry) {
+	domain, name := SplitUsername(entry.Username)
+
+	memberships := g.byName[strings.ToLower(name)]
+	if entry.RID != "" {
+		memberships = append(memberships, g.byRID[entry.RID]...)
+	}
+
+	var groups []string
+	for _, m := range memberships {
+		if sameDomain(domain, m.domain) {
+			groups = append(groups, m.group)
+		}
+	}
+	entry.PrivilegedGroups = uniqueSorted(groups)
+}
+
+// sameDomain reports whether the NetBIOS domain of an NTDS account is the
+// domain of a membership. Either being unknown matches, as does the first
+// label of an FQDN: CORP is corp.local.
+func sameDomain(netbios, domain string) bool {
+	if netbios == "" || domain == "" || strings.EqualFold(netbios, domain) {
+		return true
+	}
+	label, _, _ := strings.Cut(domain, ".")
+	return strings.EqualFold(netbios, label)
+}
+
+// add records the membership of a DOMAIN\name account
+func (g *GroupMembership) add(account, group string) {
+	domain, name := SplitUsername(account)
+	key := strings.ToLower(name)
+	g.byName[key] = append(g.byName[key], membership{domain: domain, group: group})
+}
+
+// loadText reads "group:member" lines; every group listed is taken as privileged
+func (g *GroupMembership) loadText(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := utils.CleanLine(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			continue
+		}
+		group := strings.TrimSpace(line[:idx])
+		member := strings.TrimSpace(line[idx+1:])
+		if group == "" || member == "" {
+			continue
+		}
+
+		g.privileged[strings.ToLower(group)] = true
+		g.add(member, group)
+	}
+	return scanner.Err()
+}
+
+// loadLdapDomainDump reads the memberOf attribute of ldapdomaindump's domain_users.json
+func (g *GroupMembership) loadLdapDomainDump(data []byte) error {
+	var objects []struct {
+		Attributes map[string][]interface{} `json:"attributes"`
+	}
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		sam := firstString(obj.Attributes["sAMAccountName"])
+		if sam == "" {
+			continue
+		}
+		if domain := dnDomain(firstString(obj.Attributes["distinguishedName"])); domain != "" {
+			sam = domain + "\\" + sam
+		}
+		for _, dn := range obj.Attributes["memberOf"] {
+			dnStr, ok := dn.(string)
+			if !ok {
+				continue
+			}
+			if group := commonName(dnStr); g.IsPrivileged(group) {
+				g.add(sam, group)
+			}
+		}
+	}
+	return nil
+}
+
+type bloodhoundFile struct {
+	Data []struct {
+		ObjectIdentifier string                 `json:"ObjectIdentifier"`
+		Properties       map[string]interface{} `json:"Properties"`
+		Members          []struct {
+			ObjectIdentifier string `json:"ObjectIdentifier"`
+			ObjectType       string `json:"ObjectType"`
+		} `json:"Members"`
+	} `json:"data"`
+	Meta struct {
+		Type string `json:"type"`
+	} `json:"meta"`
+}
+
+// loadBloodHound resolves (nested) privileged group members from SharpHound
+// groups.json, using users.json/computers.json to map SIDs to account names.
+// Members missing from those files are matched by RID within the domain of
+// their SID.
+func (g *GroupMembership) loadBloodHound(files []bloodhoundFile) {
+	names := make(map[string]string)
+	members := make(map[string][]string)
+	var privileged []string
+	groupNames := make(map[string]string)
+	// domains maps domain SIDs to the FQDN of the objects seen in them
+	domains := make(map[string]string)
+
+	for _, file := range files {
+		for _, obj := range file.Data {
+			sid := strings.ToUpper(obj.ObjectIdentifier)
+			name, _ := obj.Properties["name"].(string)
+			domain, _ := obj.Properties["domain"].(string)
+			if idx := strings.LastIndex(name, "@"); idx != -1 {
+				if domain == "" {
+					domain = name[idx+1:]
+				}
+				name = name[:idx]
+			}
+			if domainSID, _, ok := splitSID(sid); ok && domain != "" {
+				domains[domainSID] = domain
+			}
+			if sam, ok := obj.Properties["samaccountname"].(string); ok && sam != "" {
+				if domain != "" {
+					sam = domain + "\\" + sam
+				}
+				names[sid] = sam
+			}
+			if strings.ToLower(file.Meta.Type) != "groups" {
+				continue
+			}
+
+			groupNames[sid] = name
+			for _, member := range obj.Members {
+				members[sid] = append(members[sid], strings.ToUpper(member.ObjectIdentifier))
+			}
+			if g.IsPrivileged(name) || isPrivilegedSID(sid) {
+				privileged = append(privileged, sid)
+			}
+		}
+	}
+
+	for _, groupSID := range privileged {
+		group := groupNames[groupSID]
+		visited := map[string]bool{groupSID: true}
+		queue := append([]string(nil), members[groupSID]...)
+
+		for len(queue) > 0 {
+			sid := queue[0]
+			queue = queue[1:]
+			if visited[sid] {
+				continue
+			}
+			visited[sid] = true
+
+			// Nested group: expand its members
+			if _, isGroup := groupNames[sid]; isGroup {
+				queue = append(queue, members[sid]...)
+				continue
+			}
+
+			if sam, ok := names[sid]; ok {
+				g.add(sam, group)
+			} else if domainSID, rid, ok := splitSID(sid); ok {
+				// A domain SID of no known FQDN never matches a NetBIOS name
+				domain := domainSID
+				if fqdn, ok := domains[domainSID]; ok {
+					domain = fqdn
+				}
+				g.byRID[rid] = append(g.byRID[rid], membership{domain: domain, group: group})
+			}
+		}
+	}
+}
+
+// splitSID splits a domain account SID into the domain SID and the RID
+func splitSID(sid string) (string, string, bool) {
+	if !strings.HasPrefix(sid, "S-1-5-21-") {
+		return "", "", false
+	}
+	idx := strings.LastIndex(sid, "-")
+	return sid[:idx], sid[idx+1:], true
+}
+
+func isPrivilegedSID(sid string) bool {
+	for _, builtin := range privilegedBuiltinSIDs {
+		if strings.HasSuffix(sid, builtin) {
+			return true
+		}
+	}
+	if !strings.HasPrefix(sid, "S-1-5-21-") {
+		return false
+	}
+	for _, suffix := range privilegedSIDSuffixes {
+		if strings.HasSuffix(sid, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// commonName returns the first CN value of a distinguished name
+func commonName(dn string) string {
+	if !strings.HasPrefix(strings.ToUpper(dn), "CN=") {
+		return ""
+	}
+	value := dn[3:]
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == ',' {
+			value = value[:i]
+			break
+		}
+	}
+	return strings.ReplaceAll(value, "\\", "")
+}
+
+// dnDomain returns the FQDN from the DC components of a distinguished name
+func dnDomain(dn string) string {
+	var labels []string
+	for _, part := range strings.Split(dn, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) > 3 && strings.EqualFold(part[:3], "DC=") {
+			labels = append(labels, part[3:])
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func firstString(values []interface{}) string {
+	if len(values) == 0 {
+		return ""
+	}
+	s, _ := values[0].(string)
+	return s
+}
+
+func uniqueSorted(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var result []string
+	for _, v := range values {
+		if !seen[strings.ToLower(v)] {
+			seen[strings.ToLower(v)] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
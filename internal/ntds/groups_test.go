@@ -0,0 +1,66 @@
+package ntds
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGroupMembershipTag(t *testing.T) {
+	const corp, lab = "S-1-5-21-1-2-3", "S-1-5-21-4-5-6"
+	files := map[string]string{
+		// CORP\jdoe is in users.json; the CORP and LAB administrators (RID
+		// 500) are not, and are matched by RID within their domain
+		"groups.json": `{"data": [
+			{"ObjectIdentifier": "` + corp + `-512", "Properties": {"name": "DOMAIN ADMINS@CORP.LOCAL", "domain": "CORP.LOCAL"},
+			 "Members": [{"ObjectIdentifier": "` + corp + `-1101"}, {"ObjectIdentifier": "` + corp + `-500"}]},
+			{"ObjectIdentifier": "` + lab + `-519", "Properties": {"name": "ENTERPRISE ADMINS@LAB.LOCAL", "domain": "LAB.LOCAL"},
+			 "Members": [{"ObjectIdentifier": "` + lab + `-500"}]}
+		], "meta": {"type": "groups"}}`,
+		"users.json": `{"data": [
+			{"ObjectIdentifier": "` + corp + `-1101", "Properties": {"name": "JDOE@CORP.LOCAL", "domain": "CORP.LOCAL", "samaccountname": "jdoe"}}
+		], "meta": {"type": "users"}}`,
+		"domain_users.json": `[
+			{"attributes": {"sAMAccountName": ["asmith"], "distinguishedName": ["CN=Alice Smith,CN=Users,DC=lab,DC=local"],
+			 "memberOf": ["CN=Backup Operators,CN=Builtin,DC=lab,DC=local"]}}
+		]`,
+		"tier0.txt": "Tier0 Admins:CORP\\svc_backup\nTier0 Admins:bwayne\n",
+	}
+	dir := t.TempDir()
+	var filenames []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, path)
+	}
+	membership, err := LoadGroupMembership(filenames, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		username, rid string
+		want          []string
+	}{
+		{`CORP\jdoe`, "1101", []string{"DOMAIN ADMINS"}},
+		{`LAB\jdoe`, "1101", nil},
+		{`CORP\Administrator`, "500", []string{"DOMAIN ADMINS"}},
+		{`LAB\Administrator`, "500", []string{"ENTERPRISE ADMINS"}},
+		{`LAB\asmith`, "1102", []string{"Backup Operators"}},
+		{`CORP\asmith`, "1102", nil},
+		{`CORP\svc_backup`, "1103", []string{"Tier0 Admins"}},
+		{`LAB\svc_backup`, "1103", nil},
+		// A member without a domain matches in every domain
+		{`LAB\bwayne`, "1104", []string{"Tier0 Admins"}},
+	}
+	for _, tt := range tests {
+		entry := &Entry{Username: tt.username, RID: tt.rid}
+		membership.Tag(entry)
+		if !reflect.DeepEqual(entry.PrivilegedGroups, tt.want) {
+			t.Errorf("Tag(%s) = %q, want %q", tt.username, entry.PrivilegedGroups, tt.want)
+		}
+	}
+}
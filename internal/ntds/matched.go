@@ -0,0 +1,265 @@
+package ntds
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fisher0x/hashtocrack/internal/utils"
+)
+
+// MatchedHeader is the first line of a versioned matched file. It may be
+// followed by space-separated key=value attributes, such as
+// redacted=<strategy> when the passwords are redacted.
+const MatchedHeader = "#HashToCrack matched v1"
+
+// isMatchedHeader reports whether a cleaned line is a matched file header
+func isMatchedHeader(line string) bool {
+	return line == MatchedHeader || strings.HasPrefix(line, MatchedHeader+" ")
+}
+
+// headerAttributes returns the key=value attributes of a matched file header
+func headerAttributes(line string) map[string]string {
+	attrs := make(map[string]string)
+	for _, field := range strings.Fields(strings.TrimPrefix(line, MatchedHeader)) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			attrs[key] = value
+		}
+	}
+	return attrs
+}
+
+// EmptyLMHash is the LM hash stored when no LM hash exists
+const EmptyLMHash = "aad3b435b51404eeaad3b435b51404ee"
+
+// MatchedColumns are the tab-separated columns of a v1 matched file
+var MatchedColumns = []string{
+	"username",
+	"rid",
+	"nt_hash",
+	"lm_status",
+	"status",
+	"cracked",
+	"empty_password",
+	"password",
+}
+
+var fieldEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// escapeField escapes backslashes, tabs and line breaks in a TSV field
+func escapeField(value string) string {
+	return fieldEscaper.Replace(value)
+}
+
+// unescapeField reverses escapeField
+func unescapeField(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// Status returns "Enabled" or "Disabled"
+func (e *Entry) Status() string {
+	if e.IsDisabled {
+		return "Disabled"
+	}
+	return "Enabled"
+}
+
+// LMStatus returns "stored" when the account has a real LM hash, "none" otherwise
+func (e *Entry) LMStatus() string {
+	if e.HasLMHash {
+		return "stored"
+	}
+	return "none"
+}
+
+// WriteMatchedHeader writes the version and column header lines. A non-empty
+// redaction records the strategy the passwords were redacted with.
+func WriteMatchedHeader(w io.Writer, redaction string) error {
+	header := MatchedHeader
+	if redaction != "" {
+		header += " redacted=" + redaction
+	}
+	_, err := fmt.Fprintf(w, "%s\n%s\n", header, strings.Join(MatchedColumns, "\t"))
+	return err
+}
+
+// FormatMatchedRecord formats an entry as a v1 matched-file record
+func FormatMatchedRecord(e *CrackedEntry) string {
+	return strings.Join([]string{
+		escapeField(e.Username),
+		e.RID,
+		e.NTHash,
+		e.LMStatus(),
+		e.Status(),
+		flag(e.Cracked),
+		flag(e.Cracked && e.Password == ""),
+		escapeField(e.Password),
+	}, "\t")
+}
+
+// parseMatchedRecord parses a v1 record using the column positions from the header
+func parseMatchedRecord(line string, columns map[string]int) (*CrackedEntry, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" {
+		return nil, fmt.Errorf("empty line")
+	}
+
+	fields := strings.Split(line, "\t")
+	field := func(name string) string {
+		if idx, ok := columns[name]; ok && idx < len(fields) {
+			return fields[idx]
+		}
+		return ""
+	}
+	if len(fields) < len(columns) {
+		return nil, fmt.Errorf("invalid record: expected %d fields, got %d", len(columns), len(fields))
+	}
+
+	entry := &CrackedEntry{}
+	entry.Username = unescapeField(field("username"))
+	entry.RID = field("rid")
+	entry.NTHash = field("nt_hash")
+	entry.HasLMHash = field("lm_status") == "stored"
+	entry.IsDisabled = field("status") == "Disabled"
+	entry.IsMachine = strings.HasSuffix(entry.Username, "$")
+	entry.Cracked = field("cracked") == "1"
+	if entry.Cracked && field("empty_password") != "1" {
+		entry.Password = unescapeField(field("password"))
+	}
+
+	return entry, nil
+}
+
+// MatchedScanner reads entries from a matched file, either the versioned
+// format or the legacy username:hash:password:status format
+type MatchedScanner struct {
+	scanner   *bufio.Scanner
+	version   int
+	redaction string
+	columns   map[string]int
+	started   bool
+	skipped   int
+	entry     *CrackedEntry
+	err       error
+}
+
+// NewMatchedScanner creates a scanner over a matched file
+func NewMatchedScanner(r io.Reader) *MatchedScanner {
+	return &MatchedScanner{scanner: bufio.NewScanner(r)}
+}
+
+// Version returns the detected file format version (0 for legacy files)
+func (s *MatchedScanner) Version() int {
+	return s.version
+}
+
+// Redaction returns the strategy the passwords of the file were redacted
+// with, or "" when they are plaintext. It is known after the first Scan.
+func (s *MatchedScanner) Redaction() string {
+	return s.redaction
+}
+
+// Scan advances to the next valid entry, skipping lines that cannot be parsed
+func (s *MatchedScanner) Scan() bool {
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+
+		if !s.started {
+			s.started = true
+			if header := utils.CleanLine(line); isMatchedHeader(header) {
+				s.version = 1
+				s.redaction = headerAttributes(header)["redacted"]
+				if !s.scanner.Scan() {
+					break
+				}
+				s.columns = make(map[string]int)
+				for i, name := range strings.Split(utils.CleanLine(s.scanner.Text()), "\t") {
+					s.columns[name] = i
+				}
+				continue
+			}
+		}
+
+		var entry *CrackedEntry
+		var err error
+		if s.version == 1 {
+			entry, err = parseMatchedRecord(line, s.columns)
+		} else {
+			entry, err = ParseAnalyticsLine(line)
+		}
+		if err != nil {
+			if strings.TrimSpace(line) != "" {
+				s.skipped++
+			}
+			continue
+		}
+
+		s.entry = entry
+		return true
+	}
+
+	s.err = s.scanner.Err()
+	return false
+}
+
+// Skipped returns the number of non-blank lines that could not be parsed
+func (s *MatchedScanner) Skipped() int {
+	return s.skipped
+}
+
+// Entry returns the entry read by the last call to Scan
+func (s *MatchedScanner) Entry() *CrackedEntry {
+	return s.entry
+}
+
+// Err returns the first read error
+func (s *MatchedScanner) Err() error {
+	return s.err
+}
+
+// LatestRecords merges the records of the same account. match -watch appends
+// a new record when an account is cracked, and the later record replaces the
+// earlier one in place.
+func LatestRecords(entries []*CrackedEntry) []*CrackedEntry {
+	index := make(map[string]int, len(entries))
+	merged := entries[:0:0]
+	for _, entry := range entries {
+		key := strings.ToLower(entry.Username) + "\x00" + entry.RID
+		if i, ok := index[key]; ok {
+			merged[i] = entry
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, entry)
+	}
+	return merged
+}
@@ -0,0 +1,107 @@
+package ntds
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMatchedRoundTrip(t *testing.T) {
+	entries := []*CrackedEntry{
+		{Entry: Entry{Username: "CORP\\jdoe", RID: "1101", NTHash: "b4b9b02e6f09a9bd760f388b67351e2b"}, Password: "Summer2024!", Cracked: true},
+		{Entry: Entry{Username: "CORP\\tabs", RID: "1102", NTHash: "11111111111111111111111111111111", HasLMHash: true}, Password: "a\tb\nc\rd\\e", Cracked: true},
+		{Entry: Entry{Username: "CORP\\empty", RID: "1103", NTHash: "31d6cfe0d16ae931b73c59d7e0c089c0"}, Password: "", Cracked: true},
+		{Entry: Entry{Username: "CORP\\old", RID: "1104", NTHash: "22222222222222222222222222222222", IsDisabled: true}},
+		{Entry: Entry{Username: "CORP\\WS01$", RID: "1105", NTHash: "33333333333333333333333333333333", IsMachine: true}},
+		{Entry: Entry{Username: "CORP\\trailing", RID: "1106", NTHash: "44444444444444444444444444444444"}, Password: "pass ", Cracked: true},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMatchedHeader(&buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		record := FormatMatchedRecord(entry)
+		if strings.ContainsAny(record, "\n\r") || strings.Count(record, "\t") != len(MatchedColumns)-1 {
+			t.Errorf("record of %s is not one line of %d fields: %q", entry.Username, len(MatchedColumns), record)
+		}
+		buf.WriteString(record + "\n")
+	}
+
+	scanner := NewMatchedScanner(&buf)
+	var got []*CrackedEntry
+	for scanner.Scan() {
+		got = append(got, scanner.Entry())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if scanner.Version() != 1 || scanner.Redaction() != "" || scanner.Skipped() != 0 {
+		t.Errorf("version %d, redaction %q, %d skipped", scanner.Version(), scanner.Redaction(), scanner.Skipped())
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("read %d entries, want %d", len(got), len(entries))
+	}
+	for i, want := range entries {
+		g := got[i]
+		if g.Username != want.Username || g.RID != want.RID || g.NTHash != want.NTHash ||
+			g.Password != want.Password || g.Cracked != want.Cracked || g.IsDisabled != want.IsDisabled ||
+			g.IsMachine != want.IsMachine || g.HasLMHash != want.HasLMHash {
+			t.Errorf("entry %d = %+v, want %+v", i, g, want)
+		}
+	}
+}
+
+func TestMatchedScannerHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		version   int
+		redaction string
+		passwords []string
+	}{
+		{
+			name:      "redacted",
+			input:     MatchedHeader + " redacted=first:3\r\nusername\trid\tnt_hash\tlm_status\tstatus\tcracked\tempty_password\tpassword\r\nCORP\\\\jdoe\t1101\tb4b9\tnone\tEnabled\t1\t0\tSum********\r\n",
+			version:   1,
+			redaction: "first:3",
+			passwords: []string{"Sum********"},
+		},
+		{
+			name:      "reordered columns",
+			input:     MatchedHeader + "\npassword\tusername\trid\tnt_hash\tlm_status\tstatus\tcracked\tempty_password\nSummer2024!\tCORP\\\\jdoe\t1101\tb4b9\tnone\tEnabled\t1\t0\nshort line\n",
+			version:   1,
+			passwords: []string{"Summer2024!"},
+		},
+		{
+			name:      "legacy",
+			input:     "CORP\\jdoe:b4b9b02e6f09a9bd760f388b67351e2b:Summer2024!:Enabled\n",
+			passwords: []string{"Summer2024!"},
+		},
+	}
+	for _, tt := range tests {
+		scanner := NewMatchedScanner(strings.NewReader(tt.input))
+		var passwords []string
+		for scanner.Scan() {
+			passwords = append(passwords, scanner.Entry().Password)
+		}
+		if scanner.Version() != tt.version || scanner.Redaction() != tt.redaction {
+			t.Errorf("%s: version %d, redaction %q, want %d, %q", tt.name, scanner.Version(), scanner.Redaction(), tt.version, tt.redaction)
+		}
+		if strings.Join(passwords, "|") != strings.Join(tt.passwords, "|") {
+			t.Errorf("%s: passwords %q, want %q", tt.name, passwords, tt.passwords)
+		}
+	}
+}
+
+func TestLatestRecords(t *testing.T) {
+	records := []*CrackedEntry{
+		{Entry: Entry{Username: "CORP\\jdoe", RID: "1101"}},
+		{Entry: Entry{Username: "CORP\\asmith", RID: "1103"}},
+		{Entry: Entry{Username: "corp\\JDOE", RID: "1101"}, Password: "Summer2024!", Cracked: true},
+	}
+	got := LatestRecords(records)
+	if len(got) != 2 || got[0] != records[2] || got[1] != records[1] {
+		t.Errorf("LatestRecords kept %+v", got)
+	}
+}
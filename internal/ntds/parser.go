@@ -3,9 +3,9 @@ package ntds
 import (
 	"bufio"
 	"fmt"
-	"os"
 	"strings"
 
+	"github.com/fisher0x/hashtocrack/internal/seal"
 	"github.com/fisher0x/hashtocrack/internal/utils"
 )
 
@@ -45,6 +45,7 @@ func ParseLine(line string) (*Entry, error) {
 	entry.RID = parts[1]
 	entry.LMHash = parts[2]
 	entry.NTHash = parts[3]
+	entry.HasLMHash = entry.LMHash != "" && !strings.EqualFold(entry.LMHash, EmptyLMHash)
 
 	// Check if machine account (ends with $)
 	entry.IsMachine = strings.HasSuffix(entry.Username, "$")
@@ -52,7 +53,16 @@ func ParseLine(line string) (*Entry, error) {
 	return entry, nil
 }
 
-// ParseAnalyticsLine parses a line from analytics file (output from match mode)
+// SplitUsername splits DOMAIN\user into its domain and account name
+func SplitUsername(username string) (string, string) {
+	if idx := strings.LastIndex(username, "\\"); idx != -1 {
+		return username[:idx], username[idx+1:]
+	}
+	return "", username
+}
+
+// ParseAnalyticsLine parses a line from a legacy analytics file (output from match mode
+// before the versioned format): username:hash:password:status
 func ParseAnalyticsLine(line string) (*CrackedEntry, error) {
 	line = utils.CleanLine(line)
 	if line == "" {
@@ -88,7 +98,7 @@ func ParseAnalyticsLine(line string) (*CrackedEntry, error) {
 
 // IsAnalyticsFile checks if file is an analytics file (output from match mode)
 func IsAnalyticsFile(filename string) bool {
-	file, err := os.Open(filename)
+	file, err := seal.Open(filename)
 	if err != nil {
 		return false
 	}
@@ -97,7 +107,11 @@ func IsAnalyticsFile(filename string) bool {
 	scanner := bufio.NewScanner(file)
 	if scanner.Scan() {
 		line := utils.CleanLine(scanner.Text())
-		// Analytics file format: username:hash:password:status
+		if isMatchedHeader(line) {
+			return true
+		}
+
+		// Legacy analytics file format: username:hash:password:status
 		parts := strings.Split(line, ":")
 		if len(parts) >= 4 {
 			lastPart := parts[len(parts)-1]
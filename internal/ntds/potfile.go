@@ -2,15 +2,15 @@ package ntds
 
 import (
 	"bufio"
-	"os"
+	"encoding/hex"
 	"strings"
 
-	"github.com/fisher0x/hashtocrack/internal/utils"
+	"github.com/fisher0x/hashtocrack/internal/seal"
 )
 
 // LoadPotfile loads hashcat potfile into a map (hash -> password)
 func LoadPotfile(filename string) (map[string]string, error) {
-	file, err := os.Open(filename)
+	file, err := seal.Open(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -20,21 +20,82 @@ func LoadPotfile(filename string) (map[string]string, error) {
 	scanner := bufio.NewScanner(file)
 
 	for scanner.Scan() {
-		line := utils.CleanLine(scanner.Text())
-		if line == "" {
-			continue
+		if hash, password, ok := ParsePotLine(scanner.Text()); ok {
+			potfile[hash] = password
 		}
+	}
 
-		// Split at first colon (password might contain colons)
-		idx := strings.Index(line, ":")
-		if idx == -1 {
-			continue
-		}
+	return potfile, scanner.Err()
+}
+
+// ParsePotLine splits a potfile line into the lowercased hash and the
+// password, decoding $HEX[...] passwords. The password is kept as hashcat
+// wrote it, spaces included; only the line ending is removed. ok is false
+// for blank lines and lines without a hash.
+func ParsePotLine(line string) (hash, password string, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
 
-		hash := strings.ToLower(line[:idx])
-		password := line[idx+1:]
-		potfile[hash] = password
+	// Split at first colon (password might contain colons)
+	idx := strings.Index(line, ":")
+	if idx == -1 {
+		return "", "", false
+	}
+	hash = strings.TrimSpace(line[:idx])
+	if hash == "" {
+		return "", "", false
 	}
 
-	return potfile, scanner.Err()
+	return strings.ToLower(hash), DecodeHex(line[idx+1:]), true
+}
+
+// DecodeHex decodes a password hashcat wrote as $HEX[...] because it holds
+// control or non-ASCII bytes, or itself starts with $HEX[. Other passwords,
+// and malformed $HEX[] values, are returned unchanged.
+func DecodeHex(password string) string {
+	if !strings.HasPrefix(password, "$HEX[") || !strings.HasSuffix(password, "]") {
+		return password
+	}
+	decoded, err := hex.DecodeString(password[len("$HEX[") : len(password)-1])
+	if err != nil {
+		return password
+	}
+	return string(decoded)
+}
+
+// EncodeHex encodes a password as $HEX[...] the way hashcat writes it to a
+// potfile: when it holds control or non-ASCII bytes, or starts with $HEX[
+// and could be mistaken for an encoded password. Colons are left as they
+// are, since the hash ends at the first one.
+func EncodeHex(password string) string {
+	encode := strings.HasPrefix(password, "$HEX[")
+	for i := 0; i < len(password) && !encode; i++ {
+		encode = password[i] < 0x20 || password[i] > 0x7e
+	}
+	if !encode {
+		return password
+	}
+	return "$HEX[" + hex.EncodeToString([]byte(password)) + "]"
+}
+
+// Potfile hash types
+const (
+	// PotNT is an NT hash (hashcat -m 1000): 32 hex digits
+	PotNT = "nt"
+	// PotLM is an LM hash half (hashcat -m 3000): 16 hex digits
+	PotLM = "lm"
+	// PotOther is any other hash, such as NetNTLMv2 or Kerberos
+	PotOther = "other"
+)
+
+// PotHashType guesses the type of a potfile hash from its shape
+func PotHashType(hash string) string {
+	if _, err := hex.DecodeString(hash); err == nil {
+		switch len(hash) {
+		case 32:
+			return PotNT
+		case 16:
+			return PotLM
+		}
+	}
+	return PotOther
 }
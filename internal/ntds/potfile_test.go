@@ -0,0 +1,76 @@
+package ntds
+
+import "testing"
+
+func TestDecodeHex(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"Summer2024!", "Summer2024!"},
+		{"$HEX[68e96c6c6f]", "h\xe9llo"},
+		{"$HEX[613a62]", "a:b"},
+		{"$HEX[]", ""},
+		// Malformed values are kept as-is
+		{"$HEX[zz]", "$HEX[zz]"},
+		{"$HEX[616", "$HEX[616"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := DecodeHex(tt.password); got != tt.want {
+			t.Errorf("DecodeHex(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeHex(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"Summer2024!", "Summer2024!"},
+		{"a:b", "a:b"},
+		{"trailing ", "trailing "},
+		{"héllo", "$HEX[68c3a96c6c6f]"},
+		{"tab\there", "$HEX[7461620968657265]"},
+		{"$HEX[41]", "$HEX[244845585b34315d]"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := EncodeHex(tt.password)
+		if got != tt.want {
+			t.Errorf("EncodeHex(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+		if DecodeHex(got) != tt.password {
+			t.Errorf("DecodeHex(EncodeHex(%q)) = %q", tt.password, DecodeHex(got))
+		}
+	}
+}
+
+func TestParsePotLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		hash     string
+		password string
+		ok       bool
+	}{
+		{"8846F7EAEE8FB117AD06BDD830B7586C:password", "8846f7eaee8fb117ad06bdd830b7586c", "password", true},
+		{"8846f7eaee8fb117ad06bdd830b7586c:pass:word", "8846f7eaee8fb117ad06bdd830b7586c", "pass:word", true},
+		{"8846f7eaee8fb117ad06bdd830b7586c:$HEX[613a62]", "8846f7eaee8fb117ad06bdd830b7586c", "a:b", true},
+		{"31d6cfe0d16ae931b73c59d7e0c089c0:", "31d6cfe0d16ae931b73c59d7e0c089c0", "", true},
+		// Spaces are part of the password, the line ending is not
+		{"8846f7eaee8fb117ad06bdd830b7586c: pass word \r\n", "8846f7eaee8fb117ad06bdd830b7586c", " pass word ", true},
+		{" 8846f7eaee8fb117ad06bdd830b7586c:password", "8846f7eaee8fb117ad06bdd830b7586c", "password", true},
+		{":wrong", "", "", false},
+		{"  :wrong", "", "", false},
+		{"   ", "", "", false},
+		{"no colon", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		hash, password, ok := ParsePotLine(tt.line)
+		if hash != tt.hash || password != tt.password || ok != tt.ok {
+			t.Errorf("ParsePotLine(%q) = %q, %q, %v, want %q, %q, %v", tt.line, hash, password, ok, tt.hash, tt.password, tt.ok)
+		}
+	}
+}
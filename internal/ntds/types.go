@@ -2,35 +2,135 @@ package ntds
 
 // Entry represents a parsed NTDS entry
 type Entry struct {
-	Username   string
-	RID        string
-	LMHash     string
-	NTHash     string
-	IsDisabled bool
-	IsMachine  bool
-	RawLine    string
+	Username   string `json:"username"`
+	RID        string `json:"rid,omitempty"`
+	LMHash     string `json:"lm_hash,omitempty"`
+	NTHash     string `json:"nt_hash"`
+	IsDisabled bool   `json:"disabled"`
+	IsMachine  bool   `json:"machine"`
+	HasLMHash  bool   `json:"has_lm_hash"`
+	RawLine    string `json:"-"`
+
+	// PrivilegedGroups is set when group membership data is loaded
+	PrivilegedGroups []string `json:"privileged_groups,omitempty"`
+}
+
+// IsPrivileged reports whether the account belongs to any privileged group
+func (e *Entry) IsPrivileged() bool {
+	return len(e.PrivilegedGroups) > 0
 }
 
 // CrackedEntry represents a matched entry with password
 type CrackedEntry struct {
 	Entry
-	Password string
-	Cracked  bool
+	Password string `json:"password"`
+	Cracked  bool   `json:"cracked"`
 }
 
 // AnalyticsResult holds statistics about cracked passwords
 type AnalyticsResult struct {
-	TotalAccounts      int
-	CrackedAccounts    int
-	CrackPercentage    float64
-	LengthDistribution map[int]int
-	TopPasswords       []PasswordCount
-	ComplexCount       int
-	ComplexPercentage  float64
+	InputFile          string           `json:"input_file"`
+	IncludeDisabled    bool             `json:"include_disabled"`
+	IncludeMachines    bool             `json:"include_machines"`
+	Redacted           bool             `json:"redacted"`
+	Redaction          string           `json:"redaction,omitempty"`
+	TotalAccounts      int              `json:"total_accounts"`
+	CrackedAccounts    int              `json:"cracked_accounts"`
+	CrackPercentage    float64          `json:"crack_percentage"`
+	LengthDistribution map[int]int      `json:"length_distribution"`
+	TopPasswords       []PasswordCount  `json:"top_passwords"`
+	TopMasks           []MaskCount      `json:"top_masks"`
+	Suppressed         *SuppressedStats `json:"suppressed"`
+	ComplexCount       int              `json:"complex_count"`
+	ComplexPercentage  float64          `json:"complex_percentage"`
+	Pairs              []AccountPair    `json:"pairs"`
+	Privileged         *PrivilegedStats `json:"privileged"`
+	Accounts           []AccountSummary `json:"accounts"`
+}
+
+// AccountSummary is the per-account line of an analytics result
+type AccountSummary struct {
+	Username         string   `json:"username"`
+	Status           string   `json:"status"`
+	Cracked          bool     `json:"cracked"`
+	Password         string   `json:"password,omitempty"`
+	Length           int      `json:"length,omitempty"`
+	Compliant        bool     `json:"compliant"`
+	PolicyFailures   []string `json:"policy_failures,omitempty"`
+	PrivilegedGroups []string `json:"privileged_groups,omitempty"`
+
+	// ReuseCluster numbers the groups of accounts sharing an NT hash,
+	// largest first; 0 when the hash is not shared
+	ReuseCluster int `json:"reuse_cluster,omitempty"`
 }
 
 // PasswordCount for top passwords ranking
 type PasswordCount struct {
-	Password string
-	Count    int
+	Password string `json:"password"`
+	Count    int    `json:"count"`
+}
+
+// SuppressedPassword replaces passwords shared by fewer accounts than the
+// k-anonymity threshold wherever they would be disclosed
+const SuppressedPassword = "<suppressed>"
+
+// SuppressedStats aggregates the passwords and masks hidden by the k-anonymity
+// threshold into the "other" lines of the top passwords and masks. MinShare
+// is 0 when no threshold is set.
+type SuppressedStats struct {
+	MinShare     int `json:"min_share"`
+	Passwords    int `json:"passwords"`
+	Accounts     int `json:"accounts"`
+	Masks        int `json:"masks"`
+	MaskAccounts int `json:"mask_accounts"`
+}
+
+// MaskCount for top hashcat masks ranking
+type MaskCount struct {
+	Mask  string `json:"mask"`
+	Count int    `json:"count"`
+}
+
+// Pair reuse kinds
+const (
+	PairSameHash        = "same-hash"
+	PairSimilarPassword = "similar-password"
+)
+
+// AccountPair is a regular account and its admin counterpart with reused credentials
+type AccountPair struct {
+	User          string `json:"user"`
+	Admin         string `json:"admin"`
+	Pattern       string `json:"pattern"`
+	Kind          string `json:"kind"`
+	NTHash        string `json:"nt_hash,omitempty"`
+	UserPassword  string `json:"user_password,omitempty"`
+	AdminPassword string `json:"admin_password,omitempty"`
+	Distance      int    `json:"distance,omitempty"`
+}
+
+// SegmentStats holds crack and reuse statistics for a set of accounts
+type SegmentStats struct {
+	Accounts        int     `json:"accounts"`
+	Cracked         int     `json:"cracked"`
+	CrackPercentage float64 `json:"crack_percentage"`
+	Reused          int     `json:"reused"`
+	ReusePercentage float64 `json:"reuse_percentage"`
+}
+
+// PrivilegedAccount is a cracked account belonging to privileged groups
+type PrivilegedAccount struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Groups   []string `json:"groups"`
+}
+
+// PrivilegedStats splits crack and reuse statistics into privileged and non-privileged accounts
+type PrivilegedStats struct {
+	Privileged    SegmentStats `json:"privileged"`
+	NonPrivileged SegmentStats `json:"non_privileged"`
+	// SharedWithNonPrivileged counts privileged accounts whose NT hash is also
+	// used by a non-privileged account
+	SharedWithNonPrivileged int                 `json:"shared_with_non_privileged"`
+	CrackedAccounts         []PrivilegedAccount `json:"cracked_accounts"`
 }
@@ -0,0 +1,100 @@
+// Package ntlm computes NT hashes: the MD4 digest of the UTF-16LE encoded
+// password. MD4 is implemented here (RFC 1320) as the standard library does
+// not provide it.
+package ntlm
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math/bits"
+	"strings"
+	"unicode/utf16"
+)
+
+// NTHash returns the lowercase hex NT hash of a password
+func NTHash(password string) string {
+	return hashUnits(utf16.Encode([]rune(password)))
+}
+
+// NTHashBytes returns the NT hash of a password with each byte widened to a
+// UTF-16 unit, the way hashcat -m 1000 hashes its candidates: a Latin-1
+// password such as $HEX[68e96c6c6f] ("héllo") hashes correctly only this way
+func NTHashBytes(password string) string {
+	units := make([]uint16, len(password))
+	for i := 0; i < len(password); i++ {
+		units[i] = uint16(password[i])
+	}
+	return hashUnits(units)
+}
+
+// Verify reports whether hash is the NT hash of password, read as UTF-8 or
+// byte by byte as hashcat does
+func Verify(password, hash string) bool {
+	hash = strings.ToLower(hash)
+	return NTHash(password) == hash || NTHashBytes(password) == hash
+}
+
+// hashUnits returns the hex MD4 digest of UTF-16LE units
+func hashUnits(units []uint16) string {
+	data := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(data[2*i:], unit)
+	}
+	sum := MD4(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// MD4 returns the MD4 digest of data
+func MD4(data []byte) [16]byte {
+	// Padding: 0x80, zeros up to 56 mod 64, then the bit length
+	length := uint64(len(data)) * 8
+	padded := make([]byte, len(data), len(data)+72)
+	copy(padded, data)
+	padded = append(padded, 0x80)
+	for len(padded)%64 != 56 {
+		padded = append(padded, 0)
+	}
+	padded = binary.LittleEndian.AppendUint64(padded, length)
+
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+	var x [16]uint32
+	for block := padded; len(block) > 0; block = block[64:] {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(block[4*i:])
+		}
+		aa, bb, cc, dd := a, b, c, d
+
+		// Round 1: F(b, c, d) = (b & c) | (^b & d)
+		for _, i := range [4]int{0, 4, 8, 12} {
+			a = bits.RotateLeft32(a+(b&c|^b&d)+x[i], 3)
+			d = bits.RotateLeft32(d+(a&b|^a&c)+x[i+1], 7)
+			c = bits.RotateLeft32(c+(d&a|^d&b)+x[i+2], 11)
+			b = bits.RotateLeft32(b+(c&d|^c&a)+x[i+3], 19)
+		}
+
+		// Round 2: G(b, c, d) = majority of b, c and d
+		for _, i := range [4]int{0, 1, 2, 3} {
+			a = bits.RotateLeft32(a+(b&c|b&d|c&d)+x[i]+0x5a827999, 3)
+			d = bits.RotateLeft32(d+(a&b|a&c|b&c)+x[i+4]+0x5a827999, 5)
+			c = bits.RotateLeft32(c+(d&a|d&b|a&b)+x[i+8]+0x5a827999, 9)
+			b = bits.RotateLeft32(b+(c&d|c&a|d&a)+x[i+12]+0x5a827999, 13)
+		}
+
+		// Round 3: H(b, c, d) = b ^ c ^ d
+		for _, i := range [4]int{0, 2, 1, 3} {
+			a = bits.RotateLeft32(a+(b^c^d)+x[i]+0x6ed9eba1, 3)
+			d = bits.RotateLeft32(d+(a^b^c)+x[i+8]+0x6ed9eba1, 9)
+			c = bits.RotateLeft32(c+(d^a^b)+x[i+4]+0x6ed9eba1, 11)
+			b = bits.RotateLeft32(b+(c^d^a)+x[i+12]+0x6ed9eba1, 15)
+		}
+
+		a, b, c, d = a+aa, b+bb, c+cc, d+dd
+	}
+
+	var sum [16]byte
+	binary.LittleEndian.PutUint32(sum[0:], a)
+	binary.LittleEndian.PutUint32(sum[4:], b)
+	binary.LittleEndian.PutUint32(sum[8:], c)
+	binary.LittleEndian.PutUint32(sum[12:], d)
+	return sum
+}
@@ -0,0 +1,65 @@
+package ntlm
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestMD4(t *testing.T) {
+	// RFC 1320, appendix A.5
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", "043f8582f241db351ce627e153e7f0e4"},
+		{strings.Repeat("1234567890", 8), "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	}
+	for _, tt := range tests {
+		sum := MD4([]byte(tt.input))
+		if got := hex.EncodeToString(sum[:]); got != tt.want {
+			t.Errorf("MD4(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNTHash(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"password", "8846f7eaee8fb117ad06bdd830b7586c"},
+		{"héllo", "0e2710adc0a29ee8e4f1a9ad694570ca"},
+	}
+	for _, tt := range tests {
+		if got := NTHash(tt.password); got != tt.want {
+			t.Errorf("NTHash(%q) = %s, want %s", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		password string
+		hash     string
+		want     bool
+	}{
+		{"password", "8846f7eaee8fb117ad06bdd830b7586c", true},
+		{"password", "8846F7EAEE8FB117AD06BDD830B7586C", true},
+		{"Password", "8846f7eaee8fb117ad06bdd830b7586c", false},
+		// hashcat writes Latin-1 cracks as raw bytes: $HEX[68e96c6c6f]
+		{"h\xe9llo", "0e2710adc0a29ee8e4f1a9ad694570ca", true},
+		{"héllo", "0e2710adc0a29ee8e4f1a9ad694570ca", true},
+	}
+	for _, tt := range tests {
+		if got := Verify(tt.password, tt.hash); got != tt.want {
+			t.Errorf("Verify(%q, %s) = %v, want %v", tt.password, tt.hash, got, tt.want)
+		}
+	}
+}
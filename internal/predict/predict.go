@@ -0,0 +1,298 @@
+// Package predict guesses the passwords a user had before and will pick
+// after a known one, following the usual rotation habits: incrementing a
+// number, or moving to the next season, month, quarter or year.
+package predict
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Directions of the predictions
+const (
+	DirectionBoth     = "both"
+	DirectionNext     = "next"
+	DirectionPrevious = "previous"
+)
+
+// Guess is a predicted password. Step is positive for the passwords after
+// the known one (1 is the next one) and negative for those before it.
+type Guess struct {
+	Password string `json:"password"`
+	Step     int    `json:"step"`
+}
+
+// Periods rolled by the predictions, in calendar order. A year rolls over
+// when a period wraps around.
+var periods = [][]string{
+	{"spring", "summer", "autumn", "winter"},
+	{"january", "february", "march", "april", "may", "june", "july",
+		"august", "september", "october", "november", "december"},
+	{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"},
+}
+
+// aliases are other names of a period
+var aliases = map[string]string{"fall": "autumn"}
+
+// segment is a run of letters, digits or other characters
+type segment struct {
+	text string
+	kind rune // 'a' letters, '0' digits, '.' other
+}
+
+// template is a password split around its rolling parts
+type template struct {
+	segments []segment
+	// period is the segment holding a season, month or quarter, and
+	// periodAt the index of the name in it (quarters use the digit segment)
+	period, periodAt int
+	values           []string
+	index            int
+	// year is the segment holding a year, and number the last digit segment
+	// when no letter follows it (not the 0 of Passw0rd)
+	year, number int
+}
+
+// Predict returns the passwords up to steps rotations away from password in
+// the given direction, closest first. The next passwords of a step come
+// before the previous ones.
+func Predict(password, direction string, steps int) []Guess {
+	if password == "" {
+		return nil
+	}
+	t := parse(password)
+
+	var guesses []Guess
+	seen := map[string]bool{password: true}
+	add := func(candidate string, step int) {
+		if candidate != "" && !seen[candidate] {
+			seen[candidate] = true
+			guesses = append(guesses, Guess{candidate, step})
+		}
+	}
+	for k := 1; k <= steps; k++ {
+		for _, step := range []int{k, -k} {
+			if (step > 0 && direction == DirectionPrevious) || (step < 0 && direction == DirectionNext) {
+				continue
+			}
+			for _, candidate := range t.roll(step) {
+				add(candidate, step)
+			}
+		}
+	}
+	return guesses
+}
+
+// parse splits the password and finds its period, year and last number
+func parse(password string) *template {
+	t := &template{period: -1, year: -1, number: -1}
+	kindOf := func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r):
+			return 'a'
+		case unicode.IsDigit(r):
+			return '0'
+		}
+		return '.'
+	}
+	for _, r := range password {
+		kind := kindOf(r)
+		if n := len(t.segments); n > 0 && t.segments[n-1].kind == kind {
+			t.segments[n-1].text += string(r)
+			continue
+		}
+		t.segments = append(t.segments, segment{string(r), kind})
+	}
+
+	for i, seg := range t.segments {
+		switch seg.kind {
+		case 'a':
+			t.number = -1
+			if t.period < 0 {
+				t.findPeriod(i)
+			}
+		case '0':
+			t.number = i
+			if t.year < 0 && isYear(seg.text) {
+				t.year = i
+			}
+		}
+	}
+
+	// A two-digit number right after a period is a year: Summer24, Q3-24
+	if t.period >= 0 && t.year < 0 {
+		next := t.period + 1
+		if next < len(t.segments) && t.segments[next].kind == '.' && len(t.segments[next].text) == 1 {
+			next++
+		}
+		if next < len(t.segments) && t.segments[next].kind == '0' && len(t.segments[next].text) == 2 {
+			t.year = next
+		}
+	}
+	return t
+}
+
+// findPeriod looks for a period name ending the letter segment i: the whole
+// segment, or a capitalized word at its end as in AcmeSummer
+func (t *template) findPeriod(i int) {
+	text := t.segments[i].text
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Offsets in lower would not match text
+		return
+	}
+
+	// Quarters: Q followed by a digit from 1 to 4
+	if strings.HasSuffix(lower, "q") && wordStart(text, len(text)-1) && i+1 < len(t.segments) {
+		digits := t.segments[i+1].text
+		if t.segments[i+1].kind == '0' && len(digits) == 1 && digits >= "1" && digits <= "4" {
+			t.period, t.periodAt = i+1, 0
+			t.values = []string{"1", "2", "3", "4"}
+			t.index = int(digits[0] - '1')
+			return
+		}
+	}
+
+	best := ""
+	for _, values := range periods {
+		for j, name := range values {
+			if len(name) > len(best) && strings.HasSuffix(lower, name) && wordStart(text, len(text)-len(name)) {
+				best, t.period, t.periodAt, t.values, t.index = name, i, len(text)-len(name), values, j
+			}
+		}
+	}
+	for alias, name := range aliases {
+		if len(alias) > len(best) && strings.HasSuffix(lower, alias) && wordStart(text, len(text)-len(alias)) {
+			best, t.period, t.periodAt, t.values = alias, i, len(text)-len(alias), periods[0]
+			for j, season := range periods[0] {
+				if season == name {
+					t.index = j
+				}
+			}
+		}
+	}
+}
+
+// wordStart reports whether a word can start at byte offset i of text: at
+// its start, or at a capital letter
+func wordStart(text string, i int) bool {
+	return i == 0 || (i < len(text) && unicode.IsUpper(rune(text[i])))
+}
+
+// isYear reports whether a digit run is a year from 1950 to 2099
+func isYear(digits string) bool {
+	if len(digits) != 4 {
+		return false
+	}
+	year, _ := strconv.Atoi(digits)
+	return year >= 1950 && year <= 2099
+}
+
+// roll returns the candidates step rotations away: the period rolled (with
+// the year carried), the year rolled, or else the last number incremented
+func (t *template) roll(step int) []string {
+	var candidates []string
+	if t.period >= 0 {
+		n := len(t.values)
+		shifted := t.index + step
+		carry := floorDiv(shifted, n)
+		replace := map[int]string{t.period: t.periodText(shifted - carry*n)}
+		if carry != 0 && t.year >= 0 {
+			replace[t.year] = shiftYear(t.segments[t.year].text, carry)
+		}
+		candidates = append(candidates, t.join(replace))
+	}
+	if t.year >= 0 {
+		candidates = append(candidates, t.join(map[int]string{t.year: shiftYear(t.segments[t.year].text, step)}))
+	}
+	if t.period < 0 && t.year < 0 {
+		if t.number >= 0 {
+			candidates = append(candidates, t.join(map[int]string{t.number: shiftNumber(t.segments[t.number].text, step)}))
+		} else if step > 0 {
+			// No number yet: the next passwords usually append one
+			candidates = append(candidates, t.join(nil)+strconv.Itoa(step))
+		}
+	}
+	return candidates
+}
+
+// periodText returns the period segment with the value at index, in the
+// case of the original name
+func (t *template) periodText(index int) string {
+	seg := t.segments[t.period].text
+	value := t.values[index]
+	if t.segments[t.period].kind == '0' {
+		return value
+	}
+	return seg[:t.periodAt] + matchCase(seg[t.periodAt:], value)
+}
+
+// join rebuilds the password with some segments replaced. An empty
+// replacement drops the candidate.
+func (t *template) join(replace map[int]string) string {
+	var b strings.Builder
+	for i, seg := range t.segments {
+		text, ok := replace[i]
+		if !ok {
+			text = seg.text
+		} else if text == "" {
+			return ""
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// shiftYear adds delta to a four or two-digit year; two-digit years wrap
+// around the century
+func shiftYear(digits string, delta int) string {
+	if len(digits) != 2 {
+		return shiftNumber(digits, delta)
+	}
+	value, _ := strconv.Atoi(digits)
+	return zeroPad(((value+delta)%100+100)%100, 2)
+}
+
+// shiftNumber adds delta to a digit run, keeping its zero padding. Numbers
+// below zero give an empty string.
+func shiftNumber(digits string, delta int) string {
+	value, err := strconv.Atoi(digits)
+	if err != nil {
+		return ""
+	}
+	value += delta
+	if value < 0 {
+		return ""
+	}
+	return zeroPad(value, len(digits))
+}
+
+// zeroPad formats value with at least width digits
+func zeroPad(value, width int) string {
+	text := strconv.Itoa(value)
+	for len(text) < width {
+		text = "0" + text
+	}
+	return text
+}
+
+// matchCase writes value in the case of original: upper, capitalized or lower
+func matchCase(original, value string) string {
+	switch {
+	case strings.ToUpper(original) == original && len(original) > 1:
+		return strings.ToUpper(value)
+	case unicode.IsUpper(rune(original[0])):
+		return strings.ToUpper(value[:1]) + value[1:]
+	}
+	return value
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
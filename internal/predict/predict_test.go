@@ -0,0 +1,42 @@
+package predict
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPredict(t *testing.T) {
+	tests := []struct {
+		password  string
+		direction string
+		steps     int
+		want      []Guess
+	}{
+		{"Summer2024!", DirectionBoth, 1, []Guess{{"Autumn2024!", 1}, {"Summer2025!", 1}, {"Spring2024!", -1}, {"Summer2023!", -1}}},
+		{"Summer2024!", DirectionNext, 2, []Guess{{"Autumn2024!", 1}, {"Summer2025!", 1}, {"Winter2024!", 2}, {"Summer2026!", 2}}},
+		// The year is carried when the period wraps around
+		{"Winter24", DirectionNext, 1, []Guess{{"Spring25", 1}, {"Winter25", 1}}},
+		{"December2024", DirectionBoth, 1, []Guess{{"January2025", 1}, {"December2025", 1}, {"November2024", -1}, {"December2023", -1}}},
+		{"Q4-2024", DirectionNext, 1, []Guess{{"Q1-2025", 1}, {"Q4-2025", 1}}},
+		{"Q1-24", DirectionPrevious, 1, []Guess{{"Q4-23", -1}, {"Q1-23", -1}}},
+		// Case and a prefix before a capitalized period are kept
+		{"FALL2023", DirectionNext, 1, []Guess{{"WINTER2023", 1}, {"FALL2024", 1}}},
+		{"AcmeSummer2024", DirectionNext, 1, []Guess{{"AcmeAutumn2024", 1}, {"AcmeSummer2025", 1}}},
+		{"Jan01", DirectionNext, 1, []Guess{{"Feb01", 1}, {"Jan02", 1}}},
+		{"Acme2024", DirectionBoth, 1, []Guess{{"Acme2025", 1}, {"Acme2023", -1}}},
+		// A trailing number is incremented with its padding
+		{"Welcome1", DirectionBoth, 1, []Guess{{"Welcome2", 1}, {"Welcome0", -1}}},
+		{"Company007", DirectionNext, 2, []Guess{{"Company008", 1}, {"Company009", 2}}},
+		{"Welcome0", DirectionPrevious, 1, nil},
+		// A digit inside a word is not a counter: a number is appended
+		{"Passw0rd", DirectionBoth, 1, []Guess{{"Passw0rd1", 1}}},
+		{"Password", DirectionNext, 2, []Guess{{"Password1", 1}, {"Password2", 2}}},
+		{"", DirectionBoth, 1, nil},
+	}
+	for _, tt := range tests {
+		got := Predict(tt.password, tt.direction, tt.steps)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Predict(%q, %s, %d) = %v, want %v", tt.password, tt.direction, tt.steps, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Redaction strategies
+const (
+	// Full replaces every password with the same fixed mask
+	Full = "full"
+	// Mask shows the character class of each rune: Ulllldd!
+	Mask = "mask"
+	// First shows the first N runes
+	First = "first"
+	// Last shows the last N runes
+	Last = "last"
+	// Ends shows the first and last N runes
+	Ends = "ends"
+	// Length shows only the length
+	Length = "length"
+	// Hash shows a keyed hash, so equal passwords can still be told apart
+	Hash = "hash"
+)
+
+// DefaultMinLength is the rune count below which passwords are fully masked
+const DefaultMinLength = 8
+
+// fullMask is the fixed output of the full strategy; it hides the length too
+const fullMask = "********"
+
+// Redactor redacts passwords with one strategy
+type Redactor struct {
+	Strategy string
+	// N is the number of runes shown by the first, last and ends strategies
+	N int
+	// MinLength fully masks passwords with fewer runes than this for the
+	// strategies that disclose characters or structure
+	MinLength int
+	// Key keys the hash strategy
+	Key []byte
+}
+
+// Default returns the historical redaction: the first three runes
+func Default() *Redactor {
+	return &Redactor{Strategy: First, N: 3, MinLength: DefaultMinLength}
+}
+
+// Parse parses a strategy specification: full, mask, length, hash, or
+// first:N, last:N, ends:N
+func Parse(spec string, minLength int, key []byte) (*Redactor, error) {
+	name, arg, hasArg := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	r := &Redactor{Strategy: name, MinLength: minLength, Key: key}
+
+	switch name {
+	case Full, Mask, Length:
+		if hasArg {
+			return nil, fmt.Errorf("strategy '%s' takes no argument", name)
+		}
+	case Hash:
+		if hasArg {
+			return nil, fmt.Errorf("strategy '%s' takes no argument", name)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("strategy 'hash' requires a secret (-secret or -secret-file)")
+		}
+	case First, Last, Ends:
+		r.N = 3
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid rune count '%s' for strategy '%s'", arg, name)
+			}
+			r.N = n
+		}
+	default:
+		return nil, fmt.Errorf("unknown redaction strategy '%s' (expected full, mask, first:N, last:N, ends:N, length or hash)", spec)
+	}
+	return r, nil
+}
+
+// Redact returns the redacted form of a password. Empty passwords stay empty.
+func (r *Redactor) Redact(password string) string {
+	if password == "" {
+		return ""
+	}
+	runes := []rune(password)
+
+	switch r.Strategy {
+	case Full:
+		return fullMask
+	case Length:
+		return strings.Repeat("*", len(runes))
+	case Hash:
+		mac := hmac.New(sha256.New, r.Key)
+		mac.Write([]byte(password))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:12]
+	}
+
+	// The remaining strategies disclose characters or structure
+	if len(runes) < r.MinLength {
+		return strings.Repeat("*", len(runes))
+	}
+
+	switch r.Strategy {
+	case Mask:
+		return classMask(runes)
+	case Last:
+		return reveal(runes, 0, r.N)
+	case Ends:
+		return reveal(runes, r.N, r.N)
+	default:
+		return reveal(runes, r.N, 0)
+	}
+}
+
+// Description describes the strategy for report headers
+func (r *Redactor) Description() string {
+	switch r.Strategy {
+	case Full, Mask, Length, Hash:
+		return r.Strategy
+	default:
+		return fmt.Sprintf("%s:%d", r.Strategy, r.N)
+	}
+}
+
+// reveal keeps the first and last runes and masks the rest; passwords too
+// short to hide anything are masked entirely
+func reveal(runes []rune, first, last int) string {
+	if first+last >= len(runes) {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:first]) + strings.Repeat("*", len(runes)-first-last) + string(runes[len(runes)-last:])
+}
+
+// classMask maps each rune to its class: U upper, l lower, d digit,
+// ! special, ? anything else
+func classMask(runes []rune) string {
+	var b strings.Builder
+	for _, c := range runes {
+		switch {
+		case c == utf8.RuneError:
+			b.WriteByte('?')
+		case unicode.IsUpper(c):
+			b.WriteByte('U')
+		case unicode.IsLower(c):
+			b.WriteByte('l')
+		case unicode.IsDigit(c):
+			b.WriteByte('d')
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			b.WriteByte('!')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
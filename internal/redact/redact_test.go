@@ -0,0 +1,97 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	key := []byte("engagement key")
+	tests := []struct {
+		spec     string
+		password string
+		want     string
+	}{
+		{"first", "Summer2024!", "Sum********"},
+		{"first:0", "Summer2024!", "***********"},
+		{"last:3", "Summer2024!", "********24!"},
+		{"ends:1", "Summer2024!", "S*********!"},
+		{"ends:6", "Summer2024!", "***********"},
+		{"mask", "Summer2024!", "Ullllldddd!"},
+		{"length", "Summer2024!", "***********"},
+		{"full", "Summer2024!", "********"},
+		{"full", "a", "********"},
+		// Runes, never bytes
+		{"first:2", "Été-2024-ça", "Ét*********"},
+		{"mask", "Été-2024-ça", "Ull!dddd!ll"},
+		{"length", "Été", "***"},
+		// Short passwords are masked by the strategies that disclose
+		{"first", "Pass1!", "******"},
+		{"mask", "Pass1!", "******"},
+		{"length", "Pass1!", "******"},
+		{"first", "", ""},
+		{"full", "", ""},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.spec, DefaultMinLength, key)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.spec, err)
+		}
+		if got := r.Redact(tt.password); got != tt.want {
+			t.Errorf("%s: Redact(%q) = %q, want %q", tt.spec, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestRedactHash(t *testing.T) {
+	r, err := Parse("hash", DefaultMinLength, []byte("key one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := Parse("hash", DefaultMinLength, []byte("key two"))
+
+	a, b := r.Redact("Summer2024!"), r.Redact("Summer2024!")
+	if a != b || !strings.HasPrefix(a, "hmac:") || len(a) != len("hmac:")+12 {
+		t.Errorf("hash redaction not stable: %q, %q", a, b)
+	}
+	if a == r.Redact("Summer2025!") || a == other.Redact("Summer2024!") {
+		t.Error("hash redaction does not depend on the password and the key")
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		// err is a substring of the expected error, "" for a valid spec
+		err         string
+		description string
+	}{
+		{"first", "", "first:3"},
+		{" Last:5 ", "", "last:5"},
+		{"ends:0", "", "ends:0"},
+		{"mask", "", "mask"},
+		{"hash", "", "hash"},
+		{"first:-1", "invalid rune count", ""},
+		{"first:x", "invalid rune count", ""},
+		{"mask:2", "takes no argument", ""},
+		{"scramble", "unknown redaction strategy", ""},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.spec, DefaultMinLength, []byte("key"))
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("Parse(%q): error %v, want %q", tt.spec, err, tt.err)
+		case tt.err == "" && r.Description() != tt.description:
+			t.Errorf("Parse(%q).Description() = %q, want %q", tt.spec, r.Description(), tt.description)
+		}
+	}
+
+	if _, err := Parse("hash", DefaultMinLength, nil); err == nil {
+		t.Error("hash strategy accepted without a key")
+	}
+	if got := Default().Description(); got != "first:3" {
+		t.Errorf("Default().Description() = %q", got)
+	}
+}
@@ -0,0 +1,214 @@
+package seal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// keys is the key material used by Open, ReadFile and Create
+var keys Keys
+
+// Configure sets the key material used by Open, ReadFile and Create
+func Configure(k Keys) {
+	keys = k
+}
+
+// Sealing reports whether Create encrypts its output
+func Sealing() bool {
+	return keys.Sealing()
+}
+
+// readCloser pairs a plaintext reader with the file it reads from
+type readCloser struct {
+	io.Reader
+	file *os.File
+}
+
+func (r *readCloser) Close() error {
+	return r.file.Close()
+}
+
+// Open opens a file for reading, transparently decrypting sealed files
+func Open(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(file)
+	magic, _ := br.Peek(len(Magic))
+	if !IsSealed(magic) {
+		return &readCloser{Reader: br, file: file}, nil
+	}
+
+	if len(keys.Passphrase) == 0 && len(keys.Identities) == 0 {
+		file.Close()
+		return nil, fmt.Errorf("%s is encrypted: provide a passphrase (-passphrase-file or HASHTOCRACK_PASSPHRASE) or -identity", filename)
+	}
+	plain, err := NewReader(br, keys)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &readCloser{Reader: plain, file: file}, nil
+}
+
+// ReadFile reads a whole file, transparently decrypting sealed files
+func ReadFile(filename string) ([]byte, error) {
+	file, err := Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return data, nil
+}
+
+// File writes to a private temporary file that replaces the target on Close
+type File struct {
+	file   *os.File
+	target string
+	sealer io.WriteCloser
+	w      io.Writer
+	done   bool
+	// inPlace is set when the target is written directly, without a
+	// temporary file
+	inPlace bool
+}
+
+// Create creates a file readable only by its owner (0600). Data is written
+// to a temporary file in the same directory and renamed over the target on
+// Close, so readers never see a partial file. Devices and pipes such as
+// /dev/null or /dev/stdout are written in place instead, and a symlink is
+// followed so that its target is replaced rather than the link. The output
+// is encrypted when a passphrase with Encrypt or a recipient is configured.
+func Create(filename string) (*File, error) {
+	return create(filename, keys)
+}
+
+// CreatePlain is Create without encryption
+func CreatePlain(filename string) (*File, error) {
+	return create(filename, Keys{})
+}
+
+func create(filename string, k Keys) (*File, error) {
+	target, inPlace := resolveTarget(filename)
+	var file *os.File
+	var err error
+	if inPlace {
+		file, err = os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	} else {
+		dir, base := filepath.Split(target)
+		if dir == "" {
+			dir = "."
+		}
+		// CreateTemp creates the file with mode 0600
+		file, err = os.CreateTemp(dir, "."+base+".tmp-*")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a := &File{file: file, target: target, w: file, inPlace: inPlace}
+	if k.Sealing() {
+		sealer, err := NewWriter(file, k)
+		if err != nil {
+			a.discard()
+			return nil, err
+		}
+		a.sealer, a.w = sealer, sealer
+	}
+	return a, nil
+}
+
+// resolveTarget returns the path a file is written to, and whether it must
+// be written in place: renaming over a device, a pipe or a symlink would
+// replace it with a regular file. A symlink to a regular or missing file is
+// followed so the rename replaces its target.
+func resolveTarget(filename string) (string, bool) {
+	info, err := os.Lstat(filename)
+	if err != nil || info.Mode().IsRegular() {
+		return filename, false
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return filename, true
+	}
+	resolved, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		// Dangling link: create its target through it
+		return filename, true
+	}
+	if info, err := os.Lstat(resolved); err == nil && info.Mode().IsRegular() {
+		return resolved, false
+	}
+	return filename, true
+}
+
+func (a *File) Write(p []byte) (int, error) {
+	return a.w.Write(p)
+}
+
+// Close finishes the file and renames it over the target
+func (a *File) Close() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+
+	if a.sealer != nil {
+		if err := a.sealer.Close(); err != nil {
+			a.discard()
+			return err
+		}
+	}
+	if a.inPlace {
+		return a.file.Close()
+	}
+	if err := a.file.Sync(); err != nil {
+		a.discard()
+		return err
+	}
+	if err := a.file.Close(); err != nil {
+		os.Remove(a.file.Name())
+		return err
+	}
+	if err := os.Rename(a.file.Name(), a.target); err != nil {
+		os.Remove(a.file.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the temporary file, leaving the target untouched. A target
+// written in place keeps what was written.
+func (a *File) Abort() {
+	if a.done {
+		return
+	}
+	a.done = true
+	a.discard()
+}
+
+// Finish closes the file if writing succeeded and discards it otherwise,
+// returning the first error
+func (a *File) Finish(err error) error {
+	if err != nil {
+		a.Abort()
+		return err
+	}
+	return a.Close()
+}
+
+// discard closes the file and removes it unless it is the target itself
+func (a *File) discard() {
+	a.file.Close()
+	if !a.inPlace {
+		os.Remove(a.file.Name())
+	}
+}
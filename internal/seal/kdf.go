@@ -0,0 +1,58 @@
+package seal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// pbkdf2SHA256 derives a key from a passphrase with PBKDF2-HMAC-SHA256 (RFC 8018)
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	var counter [4]byte
+	key := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// hkdfSHA256 derives a key with HKDF-SHA256 (RFC 5869)
+func hkdfSHA256(secret, salt, info []byte, keyLen int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	var expand hash.Hash = hmac.New(sha256.New, prk)
+	var key, previous []byte
+	for counter := byte(1); len(key) < keyLen; counter++ {
+		expand.Reset()
+		expand.Write(previous)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		previous = expand.Sum(nil)
+		key = append(key, previous...)
+	}
+	return key[:keyLen]
+}
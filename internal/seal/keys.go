@@ -0,0 +1,217 @@
+package seal
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Text key prefixes
+const (
+	PublicKeyPrefix  = "htcpub1:"
+	PrivateKeyPrefix = "htcsec1:"
+)
+
+// x25519Info separates the X25519 key-wrapping key from other HKDF uses
+const x25519Info = "hashtocrack x25519 file key"
+
+// GenerateIdentity returns a new X25519 private key
+func GenerateIdentity() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// FormatPublicKey encodes a recipient key as htcpub1:<base64>
+func FormatPublicKey(key *ecdh.PublicKey) string {
+	return PublicKeyPrefix + base64.RawURLEncoding.EncodeToString(key.Bytes())
+}
+
+// FormatPrivateKey encodes an identity as htcsec1:<base64>
+func FormatPrivateKey(key *ecdh.PrivateKey) string {
+	return PrivateKeyPrefix + base64.RawURLEncoding.EncodeToString(key.Bytes())
+}
+
+// ParsePublicKey decodes a htcpub1: recipient key
+func ParsePublicKey(text string) (*ecdh.PublicKey, error) {
+	raw, err := decodeKey(text, PublicKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+// ParsePrivateKey decodes a htcsec1: identity
+func ParsePrivateKey(text string) (*ecdh.PrivateKey, error) {
+	raw, err := decodeKey(text, PrivateKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(raw)
+}
+
+func decodeKey(text, prefix string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, prefix) {
+		return nil, fmt.Errorf("key does not start with '%s'", prefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(text, prefix))
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("malformed key")
+	}
+	return raw, nil
+}
+
+// LoadRecipient parses a recipient given inline or as a file containing a
+// htcpub1: line; an identity file yields its public key
+func LoadRecipient(value string) (*ecdh.PublicKey, error) {
+	if strings.HasPrefix(value, PublicKeyPrefix) {
+		return ParsePublicKey(value)
+	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range keyLines(data) {
+		switch {
+		case strings.HasPrefix(line, PublicKeyPrefix):
+			return ParsePublicKey(line)
+		case strings.HasPrefix(line, PrivateKeyPrefix):
+			identity, err := ParsePrivateKey(line)
+			if err != nil {
+				return nil, err
+			}
+			return identity.PublicKey(), nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no %s key found", value, PublicKeyPrefix)
+}
+
+// LoadIdentities reads every htcsec1: identity from a key file
+func LoadIdentities(filename string) ([]*ecdh.PrivateKey, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var identities []*ecdh.PrivateKey
+	for _, line := range keyLines(data) {
+		if !strings.HasPrefix(line, PrivateKeyPrefix) {
+			continue
+		}
+		identity, err := ParsePrivateKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		identities = append(identities, identity)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("%s: no %s key found", filename, PrivateKeyPrefix)
+	}
+	return identities, nil
+}
+
+// keyLines returns the non-empty lines of a key file, skipping # comments
+func keyLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// passphraseStanza wraps the file key under a PBKDF2-derived key
+func passphraseStanza(passphrase, fileKey []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	wrapKey := pbkdf2SHA256(passphrase, salt, DefaultIterations, fileKeySize)
+
+	stanza := []byte{stanzaPassphrase}
+	stanza = append(stanza, salt...)
+	stanza = binary.BigEndian.AppendUint32(stanza, DefaultIterations)
+	return wrap(stanza, wrapKey, fileKey)
+}
+
+// x25519Stanza wraps the file key for a recipient with an ephemeral key pair
+func x25519Stanza(recipient *ecdh.PublicKey, fileKey []byte) ([]byte, error) {
+	ephemeral, err := GenerateIdentity()
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	wrapKey := hkdfSHA256(shared, append(ephemeralPub, recipient.Bytes()...), []byte(x25519Info), fileKeySize)
+
+	stanza := []byte{stanzaX25519}
+	stanza = append(stanza, ephemeralPub...)
+	return wrap(stanza, wrapKey, fileKey)
+}
+
+// wrap appends the file key sealed under the wrapping key. Every wrapping key
+// is derived from a fresh salt or ephemeral key, so a zero nonce is safe.
+func wrap(stanza, wrapKey, fileKey []byte) ([]byte, error) {
+	aead, err := newGCM(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(stanza, make([]byte, aead.NonceSize()), fileKey, stanza[:1]), nil
+}
+
+// unwrapStanza returns the file key if one of the keys opens the stanza, or
+// nil if none applies
+func unwrapStanza(kind byte, body []byte, keys Keys) ([]byte, error) {
+	switch kind {
+	case stanzaPassphrase:
+		if len(keys.Passphrase) == 0 {
+			return nil, nil
+		}
+		salt := body[:saltSize]
+		iterations := binary.BigEndian.Uint32(body[saltSize : saltSize+4])
+		if iterations == 0 || iterations > maxIterations {
+			return nil, fmt.Errorf("invalid PBKDF2 iteration count %d", iterations)
+		}
+		wrapKey := pbkdf2SHA256(keys.Passphrase, salt, int(iterations), fileKeySize)
+		return unwrap(kind, wrapKey, body[saltSize+4:]), nil
+
+	case stanzaX25519:
+		ephemeral, err := ecdh.X25519().NewPublicKey(body[:32])
+		if err != nil {
+			return nil, nil
+		}
+		for _, identity := range keys.Identities {
+			shared, err := identity.ECDH(ephemeral)
+			if err != nil {
+				continue
+			}
+			salt := append(append([]byte{}, body[:32]...), identity.PublicKey().Bytes()...)
+			wrapKey := hkdfSHA256(shared, salt, []byte(x25519Info), fileKeySize)
+			if fileKey := unwrap(kind, wrapKey, body[32:]); fileKey != nil {
+				return fileKey, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// unwrap opens a wrapped file key, returning nil if the key does not match
+func unwrap(kind byte, wrapKey, wrapped []byte) []byte {
+	aead, err := newGCM(wrapKey)
+	if err != nil {
+		return nil
+	}
+	fileKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), wrapped, []byte{kind})
+	if err != nil {
+		return nil
+	}
+	return fileKey
+}
@@ -0,0 +1,308 @@
+// Package seal implements the encrypted file format used for cracked
+// credential files at rest.
+//
+// A sealed file starts with a header that wraps a random file key once per
+// key holder: a passphrase stanza (PBKDF2-HMAC-SHA256, then AES-256-GCM) or an
+// X25519 recipient stanza (ephemeral ECDH, HKDF-SHA256, then AES-256-GCM). The
+// payload is split into 64 KiB chunks, each sealed with AES-256-GCM under the
+// file key. The nonce holds the chunk counter and a final-chunk flag, so
+// reordered, dropped or truncated chunks fail authentication.
+package seal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Magic starts every sealed file
+const Magic = "HTCENC\x00\x01"
+
+// Stanza types
+const (
+	stanzaPassphrase = 1
+	stanzaX25519     = 2
+)
+
+const (
+	// DefaultIterations is the PBKDF2 iteration count for new passphrase stanzas
+	DefaultIterations = 600000
+	// maxIterations bounds the work a crafted header can demand
+	maxIterations = 10000000
+
+	fileKeySize = 32
+	saltSize    = 16
+	wrappedSize = fileKeySize + 16
+	chunkSize   = 64 * 1024
+	tagSize     = 16
+	maxStanzas  = 255
+)
+
+// ErrNoKey is returned when none of the supplied keys opens a sealed file
+var ErrNoKey = errors.New("no matching passphrase or identity for encrypted file")
+
+// Keys holds the key material used to seal and open files
+type Keys struct {
+	// Passphrase seals new files when Encrypt is set and opens passphrase stanzas
+	Passphrase []byte
+	// Recipients receive a stanza in every sealed file
+	Recipients []*ecdh.PublicKey
+	// Identities open X25519 stanzas
+	Identities []*ecdh.PrivateKey
+	// Encrypt seals outputs with the passphrase; recipients always seal
+	Encrypt bool
+}
+
+// Sealing reports whether outputs are encrypted
+func (k Keys) Sealing() bool {
+	return (k.Encrypt && len(k.Passphrase) > 0) || len(k.Recipients) > 0
+}
+
+// IsSealed reports whether data starts with the sealed file magic
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Magic))
+}
+
+// writer seals a payload chunk by chunk
+type writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	ad     []byte
+	buf    []byte
+	nonce  [12]byte
+	count  uint64
+	closed bool
+}
+
+// NewWriter writes a sealed header to w and returns a writer that seals the
+// payload. Close must be called to write the final chunk; it does not close w.
+func NewWriter(w io.Writer, keys Keys) (io.WriteCloser, error) {
+	if !keys.Sealing() {
+		return nil, errors.New("no passphrase or recipient to encrypt to")
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+
+	var stanzas [][]byte
+	if keys.Encrypt && len(keys.Passphrase) > 0 {
+		stanza, err := passphraseStanza(keys.Passphrase, fileKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, stanza)
+	}
+	for _, recipient := range keys.Recipients {
+		stanza, err := x25519Stanza(recipient, fileKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, stanza)
+	}
+	if len(stanzas) > maxStanzas {
+		return nil, fmt.Errorf("too many recipients (%d, maximum %d)", len(stanzas), maxStanzas)
+	}
+
+	header := []byte(Magic)
+	header = append(header, byte(len(stanzas)))
+	for _, stanza := range stanzas {
+		header = append(header, stanza...)
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	ad := sha256.Sum256(header)
+	return &writer{w: w, aead: aead, ad: ad[:], buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (s *writer) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("seal: write after close")
+	}
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, so the last
+		// chunk is always sealed by Close with the final flag
+		if len(s.buf) == chunkSize {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[len(s.buf):chunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final chunk
+func (s *writer) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+func (s *writer) flush(final bool) error {
+	setNonce(&s.nonce, s.count, final)
+	sealed := s.aead.Seal(nil, s.nonce[:], s.buf, s.ad)
+	s.count++
+	s.buf = s.buf[:0]
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+// reader opens a sealed payload chunk by chunk
+type reader struct {
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	ad    []byte
+	buf   []byte
+	chunk []byte
+	nonce [12]byte
+	count uint64
+	done  bool
+	err   error
+}
+
+// NewReader reads a sealed header from r, unwraps the file key with the
+// first matching key and returns a reader for the plaintext. Reading fails
+// if the file was modified or truncated.
+func NewReader(r io.Reader, keys Keys) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, chunkSize+tagSize)
+
+	header := make([]byte, len(Magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("reading encrypted header: %w", unexpected(err))
+	}
+	if !IsSealed(header) {
+		return nil, errors.New("not an encrypted file")
+	}
+
+	var fileKey []byte
+	for i := 0; i < int(header[len(Magic)]); i++ {
+		kind, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading encrypted header: %w", unexpected(err))
+		}
+		var body []byte
+		switch kind {
+		case stanzaPassphrase:
+			body = make([]byte, saltSize+4+wrappedSize)
+		case stanzaX25519:
+			body = make([]byte, 32+wrappedSize)
+		default:
+			return nil, fmt.Errorf("unknown key stanza type %d", kind)
+		}
+		if _, err := io.ReadFull(br, body); err != nil {
+			return nil, fmt.Errorf("reading encrypted header: %w", unexpected(err))
+		}
+		header = append(header, kind)
+		header = append(header, body...)
+
+		if fileKey == nil {
+			if fileKey, err = unwrapStanza(kind, body, keys); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrNoKey
+	}
+
+	aead, err := newGCM(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	ad := sha256.Sum256(header)
+	return &reader{r: br, aead: aead, ad: ad[:], chunk: make([]byte, chunkSize+tagSize)}, nil
+}
+
+func (s *reader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.err = s.next()
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// next opens the following chunk. A chunk is final when it is short or when
+// nothing follows it; the final flag in its nonce must agree.
+func (s *reader) next() error {
+	n, err := io.ReadFull(s.r, s.chunk)
+	final := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		final = true
+	case err == io.EOF:
+		return errors.New("encrypted file is truncated")
+	case err != nil:
+		return err
+	default:
+		if _, err := s.r.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	setNonce(&s.nonce, s.count, final)
+	plain, err := s.aead.Open(s.chunk[:0], s.nonce[:], s.chunk[:n], s.ad)
+	if err != nil {
+		return errors.New("encrypted file is corrupted, truncated or was modified")
+	}
+	s.count++
+	s.buf = plain
+	s.done = final
+	return nil
+}
+
+// setNonce builds the chunk nonce: an 11-byte big-endian counter followed by
+// the final-chunk flag
+func setNonce(nonce *[12]byte, count uint64, final bool) {
+	*nonce = [12]byte{}
+	binary.BigEndian.PutUint64(nonce[3:11], count)
+	if final {
+		nonce[11] = 1
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// unexpected reports a clean EOF inside the header as truncation
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package seal
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// testKeys returns keys sealing to a fresh recipient and opening with its
+// identity, which is much faster than a passphrase
+func testKeys(t *testing.T) Keys {
+	t.Helper()
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Keys{Recipients: []*ecdh.PublicKey{identity.PublicKey()}, Identities: []*ecdh.PrivateKey{identity}}
+}
+
+// sealBytes seals plain with keys
+func sealBytes(t *testing.T, plain []byte, keys Keys) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	w, err := NewWriter(&sealed, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sealed.Bytes()
+}
+
+// openBytes opens sealed with keys and reads the whole plaintext
+func openBytes(sealed []byte, keys Keys) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(sealed), keys)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	keys := testKeys(t)
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 5} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		sealed := sealBytes(t, plain, keys)
+		if !IsSealed(sealed) {
+			t.Errorf("size %d: sealed data does not start with the magic", size)
+		}
+		got, err := openBytes(sealed, keys)
+		if err != nil {
+			t.Errorf("size %d: %v", size, err)
+			continue
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: plaintext does not round-trip", size)
+		}
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	plain := []byte("CORP\\jdoe\tSummer2024!\n")
+	sealed := sealBytes(t, plain, Keys{Passphrase: []byte("correct horse"), Encrypt: true})
+
+	got, err := openBytes(sealed, Keys{Passphrase: []byte("correct horse")})
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("open with the passphrase = %q, %v", got, err)
+	}
+	if _, err := openBytes(sealed, Keys{Passphrase: []byte("wrong horse")}); !errors.Is(err, ErrNoKey) {
+		t.Errorf("open with a wrong passphrase: got %v, want ErrNoKey", err)
+	}
+	if _, err := openBytes(sealed, testKeys(t)); !errors.Is(err, ErrNoKey) {
+		t.Errorf("open with another identity: got %v, want ErrNoKey", err)
+	}
+}
+
+func TestTamperDetection(t *testing.T) {
+	keys := testKeys(t)
+	plain := make([]byte, 2*chunkSize+10)
+	rand.Read(plain)
+	sealed := sealBytes(t, plain, keys)
+	headerSize := len(Magic) + 1 + 1 + 32 + wrappedSize
+	sealedChunk := chunkSize + tagSize
+
+	tests := []struct {
+		name   string
+		mutate func(data []byte) []byte
+	}{
+		{"header byte flipped", func(data []byte) []byte {
+			data[headerSize-1] ^= 1
+			return data
+		}},
+		{"payload byte flipped", func(data []byte) []byte {
+			data[headerSize+100] ^= 1
+			return data
+		}},
+		{"last byte dropped", func(data []byte) []byte {
+			return data[:len(data)-1]
+		}},
+		{"byte appended", func(data []byte) []byte {
+			return append(data, 0)
+		}},
+		{"final chunk dropped", func(data []byte) []byte {
+			return data[:headerSize+2*sealedChunk]
+		}},
+		{"chunks swapped", func(data []byte) []byte {
+			first := append([]byte{}, data[headerSize:headerSize+sealedChunk]...)
+			copy(data[headerSize:], data[headerSize+sealedChunk:headerSize+2*sealedChunk])
+			copy(data[headerSize+sealedChunk:], first)
+			return data
+		}},
+		{"header truncated", func(data []byte) []byte {
+			return data[:headerSize-1]
+		}},
+	}
+	for _, tt := range tests {
+		data := tt.mutate(append([]byte{}, sealed...))
+		if got, err := openBytes(data, keys); err == nil {
+			t.Errorf("%s: read %d bytes without error", tt.name, len(got))
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	defer Configure(Keys{})
+	keys := testKeys(t)
+	Configure(keys)
+
+	target := filepath.Join(dir, "matched.txt")
+	f, err := Create(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("secret"))
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("target exists before Close: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	data, err := ReadFile(target)
+	if err != nil || string(data) != "secret" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+
+	// An aborted file leaves the previous target and no temporary file
+	f, err = Create(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("partial"))
+	if err := f.Finish(errors.New("write failed")); err == nil {
+		t.Error("Finish with an error returned nil")
+	}
+	if data, _ := ReadFile(target); string(data) != "secret" {
+		t.Errorf("target after abort = %q, want the previous content", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files left in the directory, want 1", len(entries))
+	}
+}
+
+func TestCreateInPlace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks and /dev/null")
+	}
+	dir := t.TempDir()
+	linked := filepath.Join(dir, "linked.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(linked, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(linked, link); err != nil {
+		t.Fatal(err)
+	}
+
+	// A symlink is followed: its target is replaced, the link kept
+	f, err := CreatePlain(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("new"))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link replaced: %v, %v", info, err)
+	}
+	if data, _ := os.ReadFile(linked); string(data) != "new" {
+		t.Errorf("link target = %q, want new", data)
+	}
+
+	// A device is written in place, never renamed over
+	f, err = CreatePlain(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("discarded"))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(os.DevNull); err != nil || info.Mode().IsRegular() {
+		t.Errorf("%s replaced by a regular file: %v", os.DevNull, err)
+	}
+}
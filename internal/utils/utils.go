@@ -13,6 +13,19 @@ func CleanLine(line string) string {
 	return strings.TrimSpace(line)
 }
 
+// Truncate shortens a string to at most n runes, ending it with "..." when
+// it is cut. The ellipsis is ASCII so the chart bitmap font can draw it.
+func Truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(runes[:max(n, 0)])
+	}
+	return string(runes[:n-3]) + "..."
+}
+
 // BoolToIncluded converts a boolean to "Included" or "Excluded" string
 func BoolToIncluded(b bool) string {
 	if b {
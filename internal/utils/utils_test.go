@@ -0,0 +1,23 @@
+package utils
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"password", 8, "password"},
+		{"password1", 8, "passw..."},
+		{"ééééééééé", 8, "ééééé..."},
+		{"password", 3, "pas"},
+		{"password", 0, ""},
+		{"", 5, ""},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
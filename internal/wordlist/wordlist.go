@@ -0,0 +1,243 @@
+// Package wordlist builds engagement-specific password candidates from the
+// account names, the domain name, keywords and the dates around the audit,
+// ordered by an estimated likelihood.
+package wordlist
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultYears is the default number of past years combined with the words
+const DefaultYears = 2
+
+// minPartLength is the shortest name part used as a word
+const minPartLength = 3
+
+// Weights of the word sources. Engagement keywords and the company name are
+// the most common password bases, then the dates, then the account names.
+const (
+	keywordWeight = 10
+	domainWeight  = 8
+)
+
+// Generator collects the words and builds the candidates
+type Generator struct {
+	// Now places the seasons, months and years
+	Now time.Time
+	// Years is the number of past years combined with the words; the next
+	// year is always included
+	Years int
+	// MinLength drops shorter candidates, e.g. the minimum password length
+	// of the domain policy
+	MinLength int
+
+	words map[string]float64
+	// parts counts the accounts using each name part
+	parts map[string]int
+}
+
+// New returns a generator for the dates around now
+func New(now time.Time, years int) *Generator {
+	return &Generator{
+		Now:   now,
+		Years: years,
+		words: make(map[string]float64),
+		parts: make(map[string]int),
+	}
+}
+
+// addWord adds a word, keeping its highest weight
+func (g *Generator) addWord(word string, weight float64) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return
+	}
+	if weight > g.words[word] {
+		g.words[word] = weight
+	}
+}
+
+// AddKeyword adds an engagement keyword such as a product or city name
+func (g *Generator) AddKeyword(word string) {
+	g.addWord(word, keywordWeight)
+}
+
+// AddDomain adds the NetBIOS domain name, usually the company name
+func (g *Generator) AddDomain(netbios string) {
+	g.addWord(netbios, domainWeight)
+}
+
+// AddAccount adds an account name and its parts: "john.doe" adds john and
+// doe, "adm-jsmith2" adds adm and jsmith. Parts shared by many accounts,
+// such as common first names, weigh more.
+func (g *Generator) AddAccount(name string) {
+	name = strings.ToLower(strings.TrimSuffix(name, "$"))
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minPartLength {
+			g.parts[part]++
+		}
+	}
+	if len(parts) > 1 || (len(parts) == 1 && parts[0] != name) {
+		g.addWord(name, 1)
+	}
+}
+
+// Candidate is a password candidate and its estimated likelihood
+type Candidate struct {
+	Password string
+	Score    float64
+}
+
+// affix is a suffix, or a case or leet variant, with its relative weight
+type affix struct {
+	text   string
+	weight float64
+}
+
+// Suffixes appended to every word besides the years
+var commonSuffixes = []affix{
+	{"", 0.5}, {"1", 0.6}, {"!", 0.5}, {"123", 0.5}, {"1!", 0.4},
+	{"12", 0.3}, {"01", 0.3}, {"1234", 0.3}, {"123!", 0.3}, {"2", 0.2},
+	{"@", 0.2}, {"#", 0.2}, {"*", 0.1}, {".", 0.1}, {"?", 0.1},
+}
+
+// leet lists the usual character substitutions
+var leet = strings.NewReplacer("a", "@", "e", "3", "i", "1", "o", "0", "s", "$")
+
+// Candidates returns the deduplicated candidates, most likely first
+func (g *Generator) Candidates() []Candidate {
+	words := make(map[string]float64, len(g.words)+len(g.parts))
+	for word, weight := range g.words {
+		words[word] = weight
+	}
+	for part, count := range g.parts {
+		weight := 1 + math.Log2(float64(count))
+		if weight > words[part] {
+			words[part] = weight
+		}
+	}
+	for word, weight := range g.dateWords() {
+		if weight > words[word] {
+			words[word] = weight
+		}
+	}
+
+	suffixes := append(g.yearSuffixes(), commonSuffixes...)
+	scores := make(map[string]float64)
+	for word, weight := range words {
+		for _, variant := range variants(word) {
+			for _, suffix := range suffixes {
+				candidate := variant.text + suffix.text
+				if utf8.RuneCountInString(candidate) < g.MinLength {
+					continue
+				}
+				score := weight * variant.weight * suffix.weight
+				if score > scores[candidate] {
+					scores[candidate] = score
+				}
+			}
+		}
+	}
+
+	candidates := make([]Candidate, 0, len(scores))
+	for password, score := range scores {
+		candidates = append(candidates, Candidate{password, score})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Password < candidates[j].Password
+	})
+	return candidates
+}
+
+// variants returns the case and leet variants of a lowercase word
+func variants(word string) []affix {
+	runes := []rune(word)
+	runes[0] = unicode.ToUpper(runes[0])
+	title := string(runes)
+	return []affix{
+		{title, 1},
+		{word, 0.6},
+		{leet.Replace(title), 0.3},
+		{strings.ToUpper(word), 0.15},
+	}
+}
+
+// Season and month names, in calendar order
+var (
+	seasons = []string{"winter", "spring", "summer", "autumn"}
+	months  = []string{"january", "february", "march", "april", "may", "june",
+		"july", "august", "september", "october", "november", "december"}
+)
+
+// dateWords weighs the seasons and months, the current ones first
+func (g *Generator) dateWords() map[string]float64 {
+	words := make(map[string]float64)
+
+	// Northern hemisphere seasons: December to February is winter
+	current := int(g.Now.Month()) % 12 / 3
+	for i, season := range seasons {
+		switch (i - current + 4) % 4 {
+		case 0:
+			words[season] = 6
+		case 3:
+			// The previous season: passwords set a few months ago
+			words[season] = 4
+		default:
+			words[season] = 3
+		}
+	}
+	words["fall"] = words["autumn"] * 0.6
+
+	month := int(g.Now.Month()) - 1
+	for i, name := range months {
+		switch (month - i + 12) % 12 {
+		case 0:
+			words[name] = 4
+		case 1, 2:
+			words[name] = 3
+		default:
+			words[name] = 1.5
+		}
+	}
+	return words
+}
+
+// yearSuffixes returns the years from Years ago to next year, alone and
+// with the usual decorations
+func (g *Generator) yearSuffixes() []affix {
+	now := g.Now.Year()
+	var suffixes []affix
+	for year := now - g.Years; year <= now+1; year++ {
+		var weight float64
+		switch {
+		case year == now:
+			weight = 1
+		case year > now:
+			weight = 0.5
+		default:
+			weight = 0.8 / float64(now-year)
+		}
+		long := strconv.Itoa(year)
+		short := long[len(long)-2:]
+		suffixes = append(suffixes,
+			affix{long, weight},
+			affix{long + "!", weight * 0.9},
+			affix{short, weight * 0.5},
+			affix{short + "!", weight * 0.4},
+			affix{"@" + long, weight * 0.3},
+		)
+	}
+	return suffixes
+}
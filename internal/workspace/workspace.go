@@ -0,0 +1,319 @@
+// Package workspace manages engagement workspaces: a directory tracking the
+// NTDS inputs, potfiles, matched files and reports of an engagement, and the
+// runs that produced them, so commands can find their inputs by role.
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fisher0x/hashtocrack/internal/seal"
+)
+
+// IndexFile is the workspace index at the root of a workspace
+const IndexFile = "hashtocrack-workspace.json"
+
+// LockFile serializes the updates of the index between concurrent runs
+const LockFile = IndexFile + ".lock"
+
+// Lock timing: how long to wait for the lock, and the age after which a
+// lock left by a crashed run is taken over
+const (
+	lockTimeout = 10 * time.Second
+	lockStale   = time.Minute
+)
+
+// Version is the version of the index format
+const Version = 1
+
+// File roles
+const (
+	RoleNTDS       = "ntds"
+	RolePotfile    = "potfile"
+	RoleHashes     = "hashes"
+	RoleMatched    = "matched"
+	RoleReport     = "report"
+	RoleAnonymized = "anonymized"
+	RoleWordlist   = "wordlist"
+)
+
+// Roles lists the file roles with the directory holding them
+var Roles = []struct {
+	Role string
+	Dir  string
+}{
+	{RoleNTDS, "ntds"},
+	{RolePotfile, "potfiles"},
+	{RoleHashes, "hashes"},
+	{RoleMatched, "matched"},
+	{RoleReport, "reports"},
+	{RoleAnonymized, "anonymized"},
+	{RoleWordlist, "wordlists"},
+}
+
+// ErrNotFound is returned when no workspace contains the directory
+var ErrNotFound = errors.New("not inside a workspace")
+
+// Workspace is an engagement workspace and its index
+type Workspace struct {
+	// Root is the workspace directory
+	Root string `json:"-"`
+
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+	Runs      []Run     `json:"runs"`
+}
+
+// File is a tracked file. Paths inside the workspace are relative to its root.
+type File struct {
+	Role    string    `json:"role"`
+	Path    string    `json:"path"`
+	AddedAt time.Time `json:"added_at"`
+	// Run is the run that wrote the file, or 0 for files added by hand
+	Run int `json:"run,omitempty"`
+}
+
+// Run records one command run in the workspace
+type Run struct {
+	ID         int       `json:"id"`
+	Command    string    `json:"command"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Inputs     []File    `json:"inputs"`
+	Outputs    []File    `json:"outputs"`
+	// Manifest is the evidence manifest of the run, if one was written
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// Dir returns the directory holding the files of a role
+func Dir(role string) string {
+	for _, r := range Roles {
+		if r.Role == role {
+			return r.Dir
+		}
+	}
+	return ""
+}
+
+// Init creates a workspace in dir, which may already exist but must not be
+// a workspace yet
+func Init(dir string) (*Workspace, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(root, IndexFile)); err == nil {
+		return nil, fmt.Errorf("%s is already a workspace", dir)
+	}
+
+	for _, r := range Roles {
+		if err := os.MkdirAll(filepath.Join(root, r.Dir), 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	ws := &Workspace{
+		Root:      root,
+		Version:   Version,
+		Name:      filepath.Base(root),
+		CreatedAt: time.Now().UTC(),
+		Files:     []File{},
+		Runs:      []Run{},
+	}
+	return ws, ws.Save()
+}
+
+// Find returns the workspace containing dir, looking in dir and its parents
+func Find(dir string) (*Workspace, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, IndexFile)); err == nil {
+			return Load(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, ErrNotFound
+		}
+		dir = parent
+	}
+}
+
+// Load reads the index of the workspace at root
+func Load(root string) (*Workspace, error) {
+	data, err := os.ReadFile(filepath.Join(root, IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	ws := &Workspace{}
+	if err := json.Unmarshal(data, ws); err != nil {
+		return nil, fmt.Errorf("%s: %v", IndexFile, err)
+	}
+	if ws.Version != Version {
+		return nil, fmt.Errorf("%s: unsupported version %d", IndexFile, ws.Version)
+	}
+	ws.Root = root
+	return ws, nil
+}
+
+// Save writes the index
+func (ws *Workspace) Save() error {
+	data, err := json.MarshalIndent(ws, "", "  ")
+	if err != nil {
+		return err
+	}
+	output, err := seal.CreatePlain(filepath.Join(ws.Root, IndexFile))
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(append(data, '\n'))
+	return output.Finish(err)
+}
+
+// Rel returns the path recorded for a file: relative to the root with
+// forward slashes inside the workspace, absolute outside it
+func (ws *Workspace) Rel(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(ws.Root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Abs returns the path of a tracked file
+func (ws *Workspace) Abs(path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(ws.Root, path)
+}
+
+// Add tracks a file. A file already tracked with the same role becomes the
+// latest of its role.
+func (ws *Workspace) Add(role, path string, run int) File {
+	file := File{Role: role, Path: ws.Rel(path), AddedAt: time.Now().UTC(), Run: run}
+	for i, f := range ws.Files {
+		if f.Role == role && f.Path == file.Path {
+			ws.Files = append(ws.Files[:i], ws.Files[i+1:]...)
+			break
+		}
+	}
+	ws.Files = append(ws.Files, file)
+	return file
+}
+
+// Track tracks a file read by a run. Unlike Add, a file already tracked keeps
+// its place and the run that wrote it.
+func (ws *Workspace) Track(role, path string) File {
+	rel := ws.Rel(path)
+	for _, f := range ws.Files {
+		if f.Role == role && f.Path == rel {
+			return f
+		}
+	}
+	return ws.Add(role, path, 0)
+}
+
+// Latest returns the path of the most recently added file of a role that
+// still exists
+func (ws *Workspace) Latest(role string) (string, error) {
+	for i := len(ws.Files) - 1; i >= 0; i-- {
+		f := ws.Files[i]
+		if f.Role != role {
+			continue
+		}
+		path := ws.Abs(f.Path)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no %s file in workspace %s (add one with 'HashToCrack workspace add %s <file>')", role, ws.Name, role)
+}
+
+// OutputPath returns a new file name for the output of a command, in the
+// directory of its role
+func (ws *Workspace) OutputPath(role, command, ext string) string {
+	stamp := command + "-" + time.Now().Format("20060102-150405")
+	path := filepath.Join(ws.Root, Dir(role), stamp+ext)
+	// Runs within the same second get a counter
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(ws.Root, Dir(role), fmt.Sprintf("%s-%d%s", stamp, i, ext))
+	}
+}
+
+// lock takes the index lock file, waiting for concurrent runs to release
+// it, and returns the function releasing it
+func (ws *Workspace) lock() (func(), error) {
+	path := filepath.Join(ws.Root, LockFile)
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("workspace index is locked by another run (remove %s if none is running)", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Update applies update to the index and saves it. The index is reloaded
+// under the lock file first, so runs finishing concurrently are not lost.
+func (ws *Workspace) Update(update func()) error {
+	unlock, err := ws.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := Load(ws.Root)
+	if err != nil {
+		return err
+	}
+	*ws = *current
+	update()
+	return ws.Save()
+}
+
+// AddRun records a run and tracks its inputs and outputs
+func (ws *Workspace) AddRun(run Run) error {
+	return ws.Update(func() {
+		run.ID = len(ws.Runs) + 1
+		for i, f := range run.Inputs {
+			run.Inputs[i] = ws.Track(f.Role, f.Path)
+		}
+		for i, f := range run.Outputs {
+			run.Outputs[i] = ws.Add(f.Role, f.Path, run.ID)
+		}
+		if run.Manifest != "" {
+			run.Manifest = ws.Rel(run.Manifest)
+		}
+		ws.Runs = append(ws.Runs, run)
+	})
+}
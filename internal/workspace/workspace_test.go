@@ -0,0 +1,179 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitFind(t *testing.T) {
+	root := t.TempDir()
+	ws, err := Init(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range Roles {
+		if info, err := os.Stat(filepath.Join(root, r.Dir)); err != nil || !info.IsDir() {
+			t.Errorf("%s directory not created: %v", r.Role, err)
+		}
+	}
+	if _, err := Init(root); err == nil {
+		t.Error("Init accepted an existing workspace")
+	}
+
+	found, err := Find(filepath.Join(root, Dir(RoleReport)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Root != ws.Root || found.Name != filepath.Base(root) {
+		t.Errorf("Find = %s (%s), want %s", found.Root, found.Name, ws.Root)
+	}
+	if _, err := Find(t.TempDir()); err != ErrNotFound {
+		t.Errorf("Find outside a workspace: error %v", err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	ws, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "potfile.txt")
+	if got := ws.Rel(outside); got != outside {
+		t.Errorf("Rel(%s) = %s", outside, got)
+	}
+	inside := filepath.Join(ws.Root, "ntds", "ntds.txt")
+	if got := ws.Rel(inside); got != "ntds/ntds.txt" {
+		t.Errorf("Rel(%s) = %s", inside, got)
+	}
+	if got := ws.Abs("ntds/ntds.txt"); got != inside {
+		t.Errorf("Abs = %s, want %s", got, inside)
+	}
+
+	if _, err := ws.Latest(RoleNTDS); err == nil {
+		t.Error("Latest found a file in an empty workspace")
+	}
+	first := filepath.Join(ws.Root, "ntds", "first.txt")
+	second := filepath.Join(ws.Root, "ntds", "second.txt")
+	touch(t, first)
+	touch(t, second)
+	ws.Add(RoleNTDS, first, 0)
+	ws.Add(RoleNTDS, second, 0)
+	if latest, _ := ws.Latest(RoleNTDS); latest != second {
+		t.Errorf("Latest = %s, want %s", latest, second)
+	}
+	// Track keeps the place of a tracked file, Add makes it the latest
+	ws.Track(RoleNTDS, first)
+	if latest, _ := ws.Latest(RoleNTDS); latest != second {
+		t.Errorf("Latest after Track = %s, want %s", latest, second)
+	}
+	ws.Add(RoleNTDS, first, 0)
+	if latest, _ := ws.Latest(RoleNTDS); latest != first || len(ws.Files) != 2 {
+		t.Errorf("Latest after Add = %s with %d files", latest, len(ws.Files))
+	}
+	// Deleted files are skipped
+	if err := os.Remove(first); err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := ws.Latest(RoleNTDS); latest != second {
+		t.Errorf("Latest after removal = %s, want %s", latest, second)
+	}
+
+	path := ws.OutputPath(RoleMatched, "match", ".txt")
+	if filepath.Dir(path) != filepath.Join(ws.Root, "matched") {
+		t.Errorf("OutputPath = %s", path)
+	}
+	touch(t, path)
+	if next := ws.OutputPath(RoleMatched, "match", ".txt"); next == path {
+		t.Errorf("OutputPath reused %s", path)
+	}
+}
+
+func TestAddRunConcurrent(t *testing.T) {
+	ws, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ntds := filepath.Join(ws.Root, "ntds", "ntds.txt")
+	touch(t, ntds)
+
+	const runs = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, runs)
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every run loads its own copy of the index, as separate
+			// processes do
+			run, err := Load(ws.Root)
+			if err != nil {
+				errs <- err
+				return
+			}
+			errs <- run.AddRun(Run{
+				Command: "match",
+				Inputs:  []File{{Role: RoleNTDS, Path: ntds}},
+				Outputs: []File{{Role: RoleMatched, Path: filepath.Join(ws.Root, "matched", fmt.Sprintf("run%d.txt", i))}},
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	final, err := Load(ws.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(final.Runs) != runs || len(final.Files) != runs+1 {
+		t.Fatalf("%d runs and %d files recorded, want %d and %d", len(final.Runs), len(final.Files), runs, runs+1)
+	}
+	for i, run := range final.Runs {
+		if run.ID != i+1 || run.Outputs[0].Run != run.ID || run.Inputs[0].Path != "ntds/ntds.txt" {
+			t.Errorf("run %d: %+v", i+1, run)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(ws.Root, LockFile)); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	ws, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A lock left by a crashed run
+	lock := filepath.Join(ws.Root, LockFile)
+	touch(t, lock)
+	old := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := ws.AddRun(Run{Command: "analyze"}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > lockTimeout/2 {
+		t.Errorf("stale lock taken over after %v", elapsed)
+	}
+	if len(ws.Runs) != 1 {
+		t.Errorf("%d runs recorded", len(ws.Runs))
+	}
+}
@@ -0,0 +1,365 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Cell styles, indexes into cellXfs in styles.xml
+const (
+	styleDefault = 0
+	styleHeader  = 1
+	styleDecimal = 2
+)
+
+// Column width limits, in characters
+const (
+	minColumnWidth = 8
+	maxColumnWidth = 60
+)
+
+// Workbook is a set of worksheets written as an Office Open XML spreadsheet
+type Workbook struct {
+	sheets []*Sheet
+}
+
+// Sheet is a worksheet with a formatted, frozen and filterable header row
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]interface{}
+}
+
+// New returns an empty workbook
+func New() *Workbook {
+	return &Workbook{}
+}
+
+// AddSheet appends a worksheet with the given header row
+func (wb *Workbook) AddSheet(name string, header ...string) *Sheet {
+	sheet := &Sheet{Name: name, Header: header}
+	wb.sheets = append(wb.sheets, sheet)
+	return sheet
+}
+
+// AddRow appends a row. Values may be strings, integers, float64 or bool;
+// anything else is written as its fmt.Sprint form.
+func (s *Sheet) AddRow(values ...interface{}) {
+	s.Rows = append(s.Rows, values)
+}
+
+// Write writes the workbook as an .xlsx file
+func (wb *Workbook) Write(w io.Writer) error {
+	if len(wb.sheets) == 0 {
+		return fmt.Errorf("workbook has no sheets")
+	}
+
+	z := zip.NewWriter(w)
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", wb.contentTypes()},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", wb.workbook()},
+		{"xl/_rels/workbook.xml.rels", wb.workbookRels()},
+		{"xl/styles.xml", styles},
+	}
+	for i, sheet := range wb.sheets {
+		parts = append(parts, struct {
+			name    string
+			content string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.xml()})
+	}
+
+	for _, part := range parts {
+		f, err := z.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const rootRels = xmlHeader +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// styles defines the default, bold header and two-decimal number cell formats
+const styles = xmlHeader +
+	`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2">` +
+	`<font><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="11"/><color rgb="FFFFFFFF"/><name val="Calibri"/></font>` +
+	`</fonts>` +
+	`<fills count="3">` +
+	`<fill><patternFill patternType="none"/></fill>` +
+	`<fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FF1F2937"/><bgColor indexed="64"/></patternFill></fill>` +
+	`</fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>` +
+	`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+func (wb *Workbook) contentTypes() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := range wb.sheets {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+func (wb *Workbook) workbook() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	b.WriteString(`<sheets>`)
+	for i, sheet := range wb.sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escapeText(sheetName(sheet.Name)), i+1, i+1)
+	}
+	b.WriteString(`</sheets>`)
+
+	// Excel expects a hidden filter database name for every auto-filter
+	b.WriteString(`<definedNames>`)
+	for i, sheet := range wb.sheets {
+		fmt.Fprintf(&b, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`,
+			i, escapeText(strings.ReplaceAll(sheetName(sheet.Name), "'", "''")), sheet.filterRange(true))
+	}
+	b.WriteString(`</definedNames>`)
+	b.WriteString(`</workbook>`)
+	return b.String()
+}
+
+func (wb *Workbook) workbookRels() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range wb.sheets {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(wb.sheets)+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// columns returns the number of columns used by the header and rows
+func (s *Sheet) columns() int {
+	n := len(s.Header)
+	for _, row := range s.Rows {
+		if len(row) > n {
+			n = len(row)
+		}
+	}
+	return n
+}
+
+// filterRange returns the header and data range, e.g. A1:F20, optionally absolute
+func (s *Sheet) filterRange(absolute bool) string {
+	last := columnName(max(s.columns()-1, 0))
+	if absolute {
+		return fmt.Sprintf("$A$1:$%s$%d", last, len(s.Rows)+1)
+	}
+	return fmt.Sprintf("A1:%s%d", last, len(s.Rows)+1)
+}
+
+func (s *Sheet) xml() string {
+	columns := s.columns()
+
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0">`)
+	b.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	b.WriteString(`<selection pane="bottomLeft" activeCell="A2" sqref="A2"/>`)
+	b.WriteString(`</sheetView></sheetViews>`)
+
+	if columns > 0 {
+		b.WriteString(`<cols>`)
+		for col, width := range s.columnWidths(columns) {
+			fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, col+1, col+1, width)
+		}
+		b.WriteString(`</cols>`)
+	}
+
+	b.WriteString(`<sheetData>`)
+	header := make([]interface{}, len(s.Header))
+	for i, title := range s.Header {
+		header[i] = title
+	}
+	writeRow(&b, 1, header, styleHeader)
+	for i, row := range s.Rows {
+		writeRow(&b, i+2, row, styleDefault)
+	}
+	b.WriteString(`</sheetData>`)
+
+	if columns > 0 {
+		fmt.Fprintf(&b, `<autoFilter ref="%s"/>`, s.filterRange(false))
+	}
+	b.WriteString(`</worksheet>`)
+	return b.String()
+}
+
+// columnWidths sizes each column to its longest value within the width limits
+func (s *Sheet) columnWidths(columns int) []int {
+	widths := make([]int, columns)
+	measure := func(col int, value interface{}) {
+		// Leave room for the auto-filter drop-down button
+		if n := utf8.RuneCountInString(cellText(value)) + 3; n > widths[col] {
+			widths[col] = n
+		}
+	}
+	for i, title := range s.Header {
+		measure(i, title)
+	}
+	for _, row := range s.Rows {
+		for i, value := range row {
+			measure(i, value)
+		}
+	}
+	for i := range widths {
+		widths[i] = min(max(widths[i], minColumnWidth), maxColumnWidth)
+	}
+	return widths
+}
+
+func writeRow(b *strings.Builder, row int, values []interface{}, style int) {
+	fmt.Fprintf(b, `<row r="%d">`, row)
+	for col, value := range values {
+		ref := fmt.Sprintf("%s%d", columnName(col), row)
+		styleAttr := ""
+		if style != styleDefault {
+			styleAttr = fmt.Sprintf(` s="%d"`, style)
+		}
+
+		switch v := value.(type) {
+		case nil:
+			continue
+		case bool:
+			n := 0
+			if v {
+				n = 1
+			}
+			fmt.Fprintf(b, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, styleAttr, n)
+		case int, int64, int32, uint, uint64, uint32:
+			fmt.Fprintf(b, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+		case float64:
+			if style == styleDefault {
+				styleAttr = fmt.Sprintf(` s="%d"`, styleDecimal)
+			}
+			fmt.Fprintf(b, `<c r="%s"%s><v>%g</v></c>`, ref, styleAttr, v)
+		default:
+			text := cellText(v)
+			space := ""
+			if strings.TrimSpace(text) != text {
+				space = ` xml:space="preserve"`
+			}
+			fmt.Fprintf(b, `<c r="%s"%s t="inlineStr"><is><t%s>%s</t></is></c>`, ref, styleAttr, space, escapeText(text))
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+// cellText returns the text form of a cell value
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case float64:
+		return fmt.Sprintf("%.2f", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// columnName converts a zero-based column index to its letter name (0 -> A, 26 -> AA)
+func columnName(col int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name
+}
+
+// sheetName makes a name valid as a worksheet name: at most 31 characters
+// and none of []:*?/\
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if utf8.RuneCountInString(name) > 31 {
+		name = string([]rune(name)[:31])
+	}
+	return name
+}
+
+// escapeText escapes a string for XML character data. Characters that XML 1.0
+// cannot carry (most control characters) use the spreadsheet _xHHHH_ escape,
+// and literal _xHHHH_ sequences are protected so they are not decoded.
+func escapeText(text string) string {
+	var b strings.Builder
+	for i, r := range text {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '"':
+			b.WriteString("&quot;")
+		case r == '_' && isEscapeSequence(text[i:]):
+			b.WriteString("_x005F_")
+		case r == utf8.RuneError || (r < 0x20 && r != '\t' && r != '\n' && r != '\r') || r == 0xFFFE || r == 0xFFFF:
+			fmt.Fprintf(&b, "_x%04X_", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isEscapeSequence reports whether s starts with an _xHHHH_ escape
+func isEscapeSequence(s string) bool {
+	if len(s) < 7 || s[1] != 'x' || s[6] != '_' {
+		return false
+	}
+	for _, c := range s[2:6] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readParts returns the parts of a written workbook by name
+func readParts(t *testing.T, wb *Workbook) map[string]string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := wb.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(data)
+	}
+	return parts
+}
+
+func TestWrite(t *testing.T) {
+	wb := New()
+	accounts := wb.AddSheet("Accounts: all/cracked", "Username", "Cracked", "Length", "Share")
+	accounts.AddRow(`CORP\jdoe`, true, 8, 12.5)
+	accounts.AddRow("a<b>&\"c\" _x0041_ \x01", false, nil, nil)
+	wb.AddSheet("Summary", "Metric")
+
+	parts := readParts(t, wb)
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels",
+		"xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		content, ok := parts[name]
+		if !ok {
+			t.Errorf("missing part %s", name)
+			continue
+		}
+		if err := xml.Unmarshal([]byte(content), new(struct{})); err != nil {
+			t.Errorf("%s is not well-formed: %v", name, err)
+		}
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Accounts_ all_cracked"`) {
+		t.Errorf("sheet name not sanitized:\n%s", parts["xl/workbook.xml"])
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="A2" t="inlineStr"><is><t>CORP\jdoe</t></is></c>`,
+		`<c r="B2" t="b"><v>1</v></c>`,
+		`<c r="C2"><v>8</v></c>`,
+		`<c r="D2" s="2"><v>12.5</v></c>`,
+		`<t>a&lt;b&gt;&amp;&quot;c&quot; _x005F_x0041_ _x0001_</t>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("sheet lacks %s", cell)
+		}
+	}
+	if strings.Contains(sheet, `r="C3"`) {
+		t.Error("nil value written as a cell")
+	}
+	if !strings.Contains(sheet, `<autoFilter ref="A1:D3"/>`) {
+		t.Errorf("no auto-filter over the rows:\n%s", sheet)
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	if err := New().Write(io.Discard); err == nil {
+		t.Error("empty workbook written")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for col, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(col); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", col, got, want)
+		}
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	long := strings.Repeat("é", 40)
+	if got := sheetName(long); got != strings.Repeat("é", 31) {
+		t.Errorf("sheetName of 40 runes = %q", got)
+	}
+	if got := sheetName(`a[b]c:d*e?f/g\h`); got != "a_b_c_d_e_f_g_h" {
+		t.Errorf("sheetName = %q", got)
+	}
+}